@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	jira "github.com/andygrunwald/go-jira"
 	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
 	"github.com/spf13/cobra"
 )
 
@@ -30,11 +32,42 @@ var AssignedProjectsList string
 // AssignedExcludeProjectsList is the comma-separated list of projects to exclude
 var AssignedExcludeProjectsList string
 
+// AssignedStatusList is the comma-separated list of statuses to include
+var AssignedStatusList string
+
+// AssignedTypeList is the comma-separated list of issue types to include
+var AssignedTypeList string
+
+// AssignedPriorityList is the comma-separated list of priorities to include
+var AssignedPriorityList string
+
+// AssignedLabelList is the comma-separated list of labels to include
+var AssignedLabelList string
+
+// AssignedSprint is the sprint to include, or "current" for open sprints
+var AssignedSprint string
+
+// AssignedDueWithin only includes issues due within this window
+var AssignedDueWithin string
+
+// AssignedUpdatedSince only includes issues updated within this window
+var AssignedUpdatedSince string
+
+// AssignedGroupBy groups the output by project, status, priority, sprint or epic
+var AssignedGroupBy string
+
+// AssignedFormat is the output format: terminal, markdown or json
+var AssignedFormat string
+
+// AssignedSort is the comma-separated list of fields to sort by, prefixed with - for descending
+var AssignedSort string
+
 // releasenotesCmd represents the releasenotes command
 var assignedCmd = &cobra.Command{
 	Use:   "mine",
 	Short: "Generates a list of issues assigned to the current user",
-	Long: `The list can be filtered by explicitly specifying projects or excluding projects
+	Long: `The list can be filtered by explicitly specifying projects or excluding projects,
+as well as by status, issue type, priority, label, sprint and dates.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		url, username, apiKey := jirasetup.GetEnvVariablesOrAsk()
@@ -46,12 +79,32 @@ var assignedCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal("Couldn't log on to the Jira server.")
 		}
-		allIssues := getAssignedIssues(jiraClient)
+		if AssignedGroupBy != "" && !validGroupBy(AssignedGroupBy) {
+			log.Fatalf("--group-by must be one of %s", strings.Join(groupByFields, ", "))
+		}
+		if AssignedFormat != "terminal" && AssignedFormat != "markdown" && AssignedFormat != "json" {
+			log.Fatal("--format must be one of terminal, markdown, json")
+		}
 
-		for _, issue := range allIssues {
-			printIssue(&issue, url)
+		if TUI {
+			if err := browseIssues(jiraClient, url, func() ([]jira.Issue, error) {
+				return getAssignedIssues(jiraClient)
+			}); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
 
+		watchIssues(func() ([]jira.Issue, error) {
+			allIssues, err := getAssignedIssues(jiraClient)
+			if err != nil {
+				return nil, err
+			}
+			if err := printAssignedIssues(jiraClient, allIssues, url); err != nil {
+				return nil, err
+			}
+			return allIssues, nil
+		})
 	},
 }
 
@@ -65,57 +118,104 @@ func init() {
 	// releasenotesCmd.PersistentFlags().String("foo", "", "A help for foo")
 	assignedCmd.PersistentFlags().StringVarP(&AssignedProjectsList, "include-projects", "i", "", "comma-separated list of Jira Projects to include")
 	assignedCmd.PersistentFlags().StringVarP(&AssignedExcludeProjectsList, "exclude-projects", "x", "", "comma-separated list of Jira Projects to exclude")
+	assignedCmd.PersistentFlags().StringVar(&AssignedStatusList, "status", "", "comma-separated list of statuses to include")
+	assignedCmd.PersistentFlags().StringVar(&AssignedTypeList, "type", "", "comma-separated list of issue types to include")
+	assignedCmd.PersistentFlags().StringVar(&AssignedPriorityList, "priority", "", "comma-separated list of priorities to include")
+	assignedCmd.PersistentFlags().StringVar(&AssignedLabelList, "label", "", "comma-separated list of labels to include")
+	assignedCmd.PersistentFlags().StringVar(&AssignedSprint, "sprint", "", "sprint name to include, or \"current\" for open sprints")
+	assignedCmd.PersistentFlags().StringVar(&AssignedDueWithin, "due-within", "", "only include issues due within this window (e.g. 7d)")
+	assignedCmd.PersistentFlags().StringVar(&AssignedUpdatedSince, "updated-since", "", "only include issues updated within this window (e.g. 2w)")
+	assignedCmd.PersistentFlags().StringVar(&AssignedGroupBy, "group-by", "", "group issues by project, status, priority, sprint or epic")
+	assignedCmd.PersistentFlags().StringVar(&AssignedFormat, "format", "terminal", "output format: terminal, markdown or json")
+	assignedCmd.PersistentFlags().StringVar(&AssignedSort, "sort", "", "comma-separated sort fields, prefix with - for descending (e.g. priority,-updated)")
+	addWatchFlags(assignedCmd)
+	addTUIFlag(assignedCmd)
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// releasenotesCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func getAssignedIssues(jiraClient *jira.Client) []jira.Issue {
-	var allIssues []jira.Issue
-	queryString := makeQueryString()
+func getAssignedIssues(jiraClient *jira.Client) ([]jira.Issue, error) {
+	return searchIssues(jiraClient, makeQueryString(jiraClient), nil)
+}
 
-	// set total results > max results so it gets the request the first time
-	resultsPerPage := 100
+func makeQueryString(jiraClient *jira.Client) string {
+	query := jql.New().
+		Where("assignee", "=", jql.MustFunc("currentUser")).
+		IsEmpty("resolution").
+		In("project", jql.Split(AssignedProjectsList)...).
+		NotIn("project", jql.Split(AssignedExcludeProjectsList)...).
+		In("status", jql.Split(AssignedStatusList)...).
+		In("issuetype", jql.Split(AssignedTypeList)...).
+		In("priority", jql.Split(AssignedPriorityList)...).
+		In("labels", jql.Split(AssignedLabelList)...)
+
+	switch strings.ToLower(AssignedSprint) {
+	case "":
+	case "current":
+		query.Where("sprint", "in", jql.MustFunc("openSprints"))
+	default:
+		query.Eq("sprint", AssignedSprint)
+	}
 
-	searchOpts := jira.SearchOptions{
-		MaxResults: resultsPerPage,
-		StartAt:    0,
+	// JQL reads dates in the profile time zone
+	var location *time.Location
+	if AssignedDueWithin != "" || AssignedUpdatedSince != "" {
+		location = getProfileLocation(jiraClient)
 	}
 
-	for {
-		allIssuesPage, resp, err := jiraClient.Issue.Search(queryString, &searchOpts)
+	if AssignedDueWithin != "" {
+		dueWithin, err := parseAge(AssignedDueWithin)
 		if err != nil {
 			log.Fatal(err)
 		}
-		allIssues = append(allIssues, allIssuesPage...)
-
-		if resp.Total < (resp.StartAt + resp.MaxResults) {
-			break
+		query.Where("due", "<=", jql.String(time.Now().In(location).Add(dueWithin).Format("2006-01-02")))
+	}
+	if AssignedUpdatedSince != "" {
+		updatedSince, err := parseAge(AssignedUpdatedSince)
+		if err != nil {
+			log.Fatal(err)
 		}
-		searchOpts.StartAt = searchOpts.StartAt + resultsPerPage
-
+		query.Where("updated", ">=", jql.String(jqlTime(time.Now().In(location).Add(-updatedSince))))
 	}
-	return allIssues
 
+	return query.SortBy(AssignedSort).String()
 }
 
-func makeQueryString() string {
-	var sb strings.Builder
-	sb.WriteString("assignee = currentUser() AND resolution IS EMPTY")
-	if AssignedProjectsList != "" {
-		sb.WriteString(" AND project in (")
-		sb.WriteString(AssignedProjectsList)
-		sb.WriteString(")")
-	} else if AssignedExcludeProjectsList != "" {
-		sb.WriteString(" AND project NOT in (")
-		sb.WriteString(AssignedExcludeProjectsList)
-		sb.WriteString(")")
+func printAssignedIssues(jiraClient *jira.Client, allIssues []jira.Issue, url string) error {
+	var groups []IssueGroup
+	if AssignedGroupBy != "" {
+		var err error
+		groups, err = groupIssues(jiraClient, allIssues, AssignedGroupBy)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case AssignedFormat == "json":
+		printIssuesJSON(allIssues, groups, AssignedGroupBy, url)
+	case AssignedGroupBy != "":
+		printIssueGroups(groups, len(allIssues), url, AssignedFormat)
+	case AssignedFormat == "markdown":
+		for _, issue := range allIssues {
+			fmt.Print(getMarkdownIssue(&issue, url))
+		}
+	default:
+		for _, issue := range allIssues {
+			printIssue(&issue, url)
+		}
 	}
-	return sb.String()
+	return nil
 }
 
 func printIssue(i *jira.Issue, baseURL string) {
-	markdownIssue := fmt.Sprintf("- [%s] %s (%s) -- %s\n\t(%s/browse/%s)", i.Key, i.Fields.Summary, i.Fields.Type.Name, i.Fields.Status.Name, baseURL, i.Key)
+	markdownIssue := fmt.Sprintf("- [%s] %s (%s) -- %s\n\t(%s)", i.Key, i.Fields.Summary, i.Fields.Type.Name, i.Fields.Status.Name, browseURL(baseURL, i.Key))
 	fmt.Println(markdownIssue)
 }
+
+// browseURL returns the link to an issue in the Jira web interface
+func browseURL(baseURL string, key string) string {
+	return fmt.Sprintf("%s/browse/%s", baseURL, key)
+}
@@ -0,0 +1,378 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/spf13/cobra"
+)
+
+// ChartFrom is the first day of the time series
+var ChartFrom string
+
+// ChartTo is the last day of the time series
+var ChartTo string
+
+// ChartPoints sums story points instead of counting issues
+var ChartPoints bool
+
+// ChartASCII draws the time series in the terminal instead of printing CSV
+var ChartASCII bool
+
+// ChartSVG is a file to write the time series to as an SVG chart
+var ChartSVG string
+
+// dailyStatus is the status each issue was in at the end of each day
+type dailyStatus struct {
+	Days   []time.Time
+	Issues []jira.Issue
+	Points []float64
+	// Status[day][issue] is the status id, empty before the issue was created
+	Status [][]string
+	Names  map[string]string
+}
+
+// cfdCmd represents the metrics cfd command
+var cfdCmd = &cobra.Command{
+	Use:   "cfd",
+	Short: "Cumulative flow: daily issue counts per status",
+	Long: `Reconstructs how many issues were in each status at the end of each day
+from the issue changelogs. Statuses are ordered from done at the bottom to
+to do at the top, the way cumulative flow diagrams are usually drawn.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+		categories := getStatusCategories(jiraClient)
+		daily := getDailyStatus(jiraClient)
+
+		totals := map[string]float64{}
+		for _, statuses := range daily.Status {
+			for i, id := range statuses {
+				if id != "" {
+					totals[id] += daily.Points[i]
+				}
+			}
+		}
+		ids := make([]string, 0, len(totals))
+		for id := range totals {
+			ids = append(ids, id)
+		}
+		categoryOrder := map[string]int{"done": 0, "indeterminate": 1, "new": 2}
+		sort.Slice(ids, func(i, j int) bool {
+			ci, cj := categoryOrder[categories[ids[i]]], categoryOrder[categories[ids[j]]]
+			if ci != cj {
+				return ci < cj
+			}
+			return daily.Names[ids[i]] < daily.Names[ids[j]]
+		})
+
+		var series []chartSeries
+		for _, id := range ids {
+			values := make([]float64, len(daily.Days))
+			for day, statuses := range daily.Status {
+				for i, status := range statuses {
+					if status == id {
+						values[day] += daily.Points[i]
+					}
+				}
+			}
+			series = append(series, chartSeries{Name: daily.Names[id], Values: values})
+		}
+		outputTimeSeries("Cumulative flow", daily.Days, series)
+	},
+}
+
+// burndownCmd represents the metrics burndown command
+var burndownCmd = &cobra.Command{
+	Use:   "burndown",
+	Short: "Burndown and burnup: daily scope, completed and remaining work",
+	Long: `Reconstructs the scope, completed and remaining work at the end of each day
+from the issue changelogs. An issue counts towards the scope from the day it
+was created, or with --sprint while it is in the sprint, and as completed while
+it is in a done status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+		categories := getStatusCategories(jiraClient)
+		daily := getDailyStatus(jiraClient)
+
+		// completed and remaining add up to the scope, so only they are stacked
+		scope := chartSeries{Name: "Scope", Values: make([]float64, len(daily.Days)), Outline: true}
+		completed := chartSeries{Name: "Completed", Values: make([]float64, len(daily.Days))}
+		remaining := chartSeries{Name: "Remaining", Values: make([]float64, len(daily.Days))}
+		for day, statuses := range daily.Status {
+			for i, id := range statuses {
+				if id == "" {
+					continue
+				}
+				scope.Values[day] += daily.Points[i]
+				if categories[id] == "done" {
+					completed.Values[day] += daily.Points[i]
+				} else {
+					remaining.Values[day] += daily.Points[i]
+				}
+			}
+		}
+		outputTimeSeries("Burndown", daily.Days, []chartSeries{scope, completed, remaining})
+	},
+}
+
+func init() {
+	metricsCmd.AddCommand(cfdCmd)
+	metricsCmd.AddCommand(burndownCmd)
+
+	for _, c := range []*cobra.Command{cfdCmd, burndownCmd} {
+		c.Flags().StringVar(&ChartFrom, "from", "", "first day, YYYY-MM-DD (default is the sprint or version start, or the day the first issue was created)")
+		c.Flags().StringVar(&ChartTo, "to", "", "last day, YYYY-MM-DD (default is today, or the day the sprint was completed or the version released)")
+		c.Flags().BoolVar(&ChartPoints, "points", false, "sum story points instead of counting issues")
+		c.Flags().BoolVar(&ChartASCII, "chart", false, "draw an ASCII chart instead of printing CSV")
+		c.Flags().StringVar(&ChartSVG, "svg", "", "also write the chart to this SVG file")
+	}
+}
+
+func parseChartDay(day string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", day, time.Local)
+	if err != nil {
+		log.Fatalf("Invalid date %q, use YYYY-MM-DD", day)
+	}
+	return t
+}
+
+// getDailyStatus fetches the metrics issues and replays their changelogs to find the
+// status of every issue at the end of each day between --from and --to
+func getDailyStatus(jiraClient *jira.Client) dailyStatus {
+	fields := []string{"summary", "status", "created"}
+	sprintFieldID := ""
+	if MetricsSprint != "" {
+		sprintFieldID = getCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+		if sprintFieldID != "" {
+			fields = append(fields, sprintFieldID)
+		}
+	}
+	if MetricsVersion != "" {
+		fields = append(fields, "fixVersions")
+	}
+	pointsFieldID := ""
+	if ChartPoints {
+		pointsFieldID = getStoryPointsFieldID(jiraClient)
+		if pointsFieldID == "" {
+			log.Fatal("Couldn't find a story points field")
+		}
+		fields = append(fields, pointsFieldID)
+	}
+
+	issues := searchAllIssues(jiraClient, makeMetricsQuery().String(), &jira.SearchOptions{
+		Expand: "changelog",
+		Fields: fields,
+	})
+	if len(issues) == 0 {
+		log.Fatal("No issues found")
+	}
+
+	daily := dailyStatus{Issues: issues, Names: map[string]string{}}
+	sprints := getMetricsSprints(issues, sprintFieldID)
+	start, end := getMetricsWindow(issues, sprints)
+	var from, to time.Time
+	if ChartFrom != "" {
+		from = parseChartDay(ChartFrom)
+	} else if !start.IsZero() {
+		from = startOfDay(start.Local())
+	} else {
+		for _, issue := range issues {
+			if created := time.Time(issue.Fields.Created); from.IsZero() || created.Before(from) {
+				from = created
+			}
+		}
+		from = startOfDay(from.Local())
+	}
+	to = startOfDay(time.Now())
+	if ChartTo != "" {
+		to = parseChartDay(ChartTo)
+	} else if !end.IsZero() && end.Before(to) {
+		to = startOfDay(end.Local())
+	}
+	if to.Before(from) {
+		log.Fatal("--to must not be before --from")
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		daily.Days = append(daily.Days, day)
+	}
+
+	daily.Status = make([][]string, len(daily.Days))
+	for day := range daily.Status {
+		daily.Status[day] = make([]string, len(issues))
+	}
+	for i, issue := range issues {
+		points := 1.0
+		if ChartPoints {
+			// the current estimate is used for every day, changes to it are not replayed
+			points = getStoryPoints(&issues[i], pointsFieldID)
+		}
+		daily.Points = append(daily.Points, points)
+
+		changes := getStatusChanges(&issues[i])
+		id, name := getInitialStatus(&issues[i], changes)
+		daily.Names[id] = name
+		created := time.Time(issue.Fields.Created)
+		memberships := getSprintMemberships(&issues[i], sprints, sprintFieldID)
+		next := 0
+		for day, start := range daily.Days {
+			end := start.AddDate(0, 0, 1)
+			if !created.Before(end) {
+				continue
+			}
+			for next < len(changes) && changes[next].At.Before(end) {
+				id = changes[next].ToID
+				daily.Names[id] = changes[next].To
+				next++
+			}
+			// with --sprint an issue is only in scope while it is in the sprint
+			if len(sprints) > 0 && !memberships.inSprintAt(created, end.Add(-time.Nanosecond)) {
+				continue
+			}
+			daily.Status[day][i] = id
+		}
+	}
+	return daily
+}
+
+// getMetricsSprints returns the sprints matching --sprint that the issues have been in:
+// the active sprints for "current", or the sprints with the given name
+func getMetricsSprints(issues []jira.Issue, sprintFieldID string) []jira.Sprint {
+	var sprints []jira.Sprint
+	seen := map[int]bool{}
+	for i := range issues {
+		for _, sprint := range getIssueSprints(&issues[i], sprintFieldID) {
+			match := strings.EqualFold(sprint.Name, MetricsSprint)
+			if strings.EqualFold(MetricsSprint, "current") {
+				match = sprint.State == "active"
+			}
+			if match && !seen[sprint.ID] {
+				seen[sprint.ID] = true
+				sprints = append(sprints, sprint)
+			}
+		}
+	}
+	return sprints
+}
+
+// getMetricsWindow returns when the --sprint started and was completed, or the
+// start and release dates of the --version. Either is zero when it isn't known.
+func getMetricsWindow(issues []jira.Issue, sprints []jira.Sprint) (time.Time, time.Time) {
+	var start, end time.Time
+	if len(sprints) > 0 {
+		for _, sprint := range sprints {
+			if t := sprintTime(sprint.StartDate); !t.IsZero() && (start.IsZero() || t.Before(start)) {
+				start = t
+			}
+			if t := sprintTime(sprint.CompleteDate); !t.IsZero() && t.After(end) {
+				end = t
+			}
+		}
+		return start, end
+	}
+
+	if MetricsVersion == "" {
+		return start, end
+	}
+	for _, issue := range issues {
+		for _, version := range issue.Fields.FixVersions {
+			if !strings.EqualFold(version.Name, MetricsVersion) {
+				continue
+			}
+			if version.StartDate != "" {
+				start = parseChartDay(version.StartDate)
+			}
+			if version.ReleaseDate != "" {
+				end = parseChartDay(version.ReleaseDate)
+			}
+			return start, end
+		}
+	}
+	return start, end
+}
+
+// sprintMemberships records whether an issue was in each of the --sprint sprints
+// when it was created and when it was added to or removed from them since
+type sprintMemberships []struct {
+	initial bool
+	changes []sprintChange
+}
+
+func getSprintMemberships(issue *jira.Issue, sprints []jira.Sprint, sprintFieldID string) sprintMemberships {
+	inSprintNow := map[int]bool{}
+	for _, sprint := range getIssueSprints(issue, sprintFieldID) {
+		inSprintNow[sprint.ID] = true
+	}
+	memberships := make(sprintMemberships, len(sprints))
+	for i, sprint := range sprints {
+		memberships[i].initial, memberships[i].changes = getSprintChanges(issue, sprint.ID, inSprintNow[sprint.ID])
+	}
+	return memberships
+}
+
+// inSprintAt tells whether the issue was in any of the sprints at time t
+func (m sprintMemberships) inSprintAt(created time.Time, t time.Time) bool {
+	for _, membership := range m {
+		if inSprintAt(created, membership.initial, membership.changes, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// outputTimeSeries prints the series as CSV or an ASCII chart, and writes the SVG file if asked
+func outputTimeSeries(title string, days []time.Time, series []chartSeries) {
+	labels := make([]string, len(days))
+	for i, day := range days {
+		labels[i] = day.Format("2006-01-02")
+	}
+
+	if ChartASCII {
+		fmt.Print(formatASCIIChart(labels, series, 80, 20))
+	} else {
+		csvWriter := csv.NewWriter(os.Stdout)
+		header := []string{"Date"}
+		for _, s := range series {
+			header = append(header, s.Name)
+		}
+		csvWriter.Write(header)
+		for i, label := range labels {
+			record := []string{label}
+			for _, s := range series {
+				record = append(record, strconv.FormatFloat(s.Values[i], 'f', -1, 64))
+			}
+			csvWriter.Write(record)
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ChartSVG != "" {
+		if err := writeSVGChart(ChartSVG, title, labels, series); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
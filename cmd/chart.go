@@ -0,0 +1,205 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+)
+
+// chartSeries is one named band of a stacked chart. An outline series isn't
+// stacked but drawn as a line over the bands, such as the scope of a burndown.
+type chartSeries struct {
+	Name    string
+	Values  []float64
+	Outline bool
+}
+
+var chartSymbols = []string{"#", "=", "+", "*", "o", "%", "@", "~", ":", "."}
+
+var chartColors = []string{"#4c78a8", "#f58518", "#54a24b", "#e45756", "#72b7b2", "#eeca3b", "#b279a2", "#ff9da6", "#9d755d", "#bab0ac"}
+
+// chartMax returns the height of the tallest stack or outline
+func chartMax(series []chartSeries) float64 {
+	var most float64
+	if len(series) == 0 {
+		return most
+	}
+	for i := range series[0].Values {
+		var total float64
+		for _, s := range series {
+			if s.Outline {
+				most = math.Max(most, s.Values[i])
+				continue
+			}
+			total += s.Values[i]
+		}
+		most = math.Max(most, total)
+	}
+	return most
+}
+
+// formatASCIIChart draws the series stacked on top of each other, the first series
+// at the bottom, with one column per label, and outline series as a line over the
+// stack. Long series are sampled down to width columns.
+func formatASCIIChart(labels []string, series []chartSeries, width int, height int) string {
+	if len(labels) == 0 || len(series) == 0 {
+		return ""
+	}
+	columns := len(labels)
+	if columns > width {
+		columns = width
+	}
+	most := chartMax(series)
+	if most == 0 {
+		most = 1
+	}
+
+	// each cell holds the symbol of the band covering it
+	grid := make([][]string, height)
+	for row := range grid {
+		grid[row] = make([]string, columns)
+		for col := range grid[row] {
+			grid[row][col] = " "
+		}
+	}
+	step := columns - 1
+	if step < 1 {
+		step = 1
+	}
+	for col := 0; col < columns; col++ {
+		index := col * (len(labels) - 1) / step
+		var top float64
+		for s, band := range series {
+			if band.Outline {
+				continue
+			}
+			bottom := top
+			top += band.Values[index]
+			for row := int(math.Round(bottom / most * float64(height))); row < int(math.Round(top/most*float64(height))) && row < height; row++ {
+				grid[height-1-row][col] = chartSymbols[s%len(chartSymbols)]
+			}
+		}
+		for s, line := range series {
+			if !line.Outline {
+				continue
+			}
+			row := int(math.Round(line.Values[index]/most*float64(height))) - 1
+			if row < 0 {
+				row = 0
+			}
+			if row >= height {
+				row = height - 1
+			}
+			grid[height-1-row][col] = chartSymbols[s%len(chartSymbols)]
+		}
+	}
+
+	var sb strings.Builder
+	axis := fmt.Sprintf("%g", math.Round(most*10)/10)
+	pad := strings.Repeat(" ", len(axis))
+	for row, cells := range grid {
+		label := pad
+		if row == 0 {
+			label = axis
+		}
+		sb.WriteString(fmt.Sprintf("%s |%s\n", label, strings.Join(cells, "")))
+	}
+	sb.WriteString(fmt.Sprintf("%s +%s\n", fmt.Sprintf("%*s", len(axis), "0"), strings.Repeat("-", columns)))
+	last := labels[len(labels)-1]
+	gap := columns - len(labels[0]) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	sb.WriteString(fmt.Sprintf("%s  %s%s%s\n\n", pad, labels[0], strings.Repeat(" ", gap), last))
+	for s := len(series) - 1; s >= 0; s-- {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", chartSymbols[s%len(chartSymbols)], series[s].Name))
+	}
+	return sb.String()
+}
+
+// writeSVGChart writes the series as a stacked area chart, the first series at the
+// bottom, with outline series drawn as lines over the areas
+func writeSVGChart(path string, title string, labels []string, series []chartSeries) error {
+	const (
+		width, height = 900.0, 420.0
+		left, right   = 50.0, 180.0
+		top, bottom   = 40.0, 40.0
+	)
+	plotWidth, plotHeight := width-left-right, height-top-bottom
+	most := chartMax(series)
+	if most == 0 {
+		most = 1
+	}
+	x := func(i int) float64 {
+		if len(labels) < 2 {
+			return left
+		}
+		return left + float64(i)/float64(len(labels)-1)*plotWidth
+	}
+	y := func(v float64) float64 { return top + plotHeight - v/most*plotHeight }
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" font-family="sans-serif" font-size="12">`+"\n", width, height))
+	sb.WriteString(fmt.Sprintf(`<rect width="%g" height="%g" fill="white"/>`+"\n", width, height))
+	sb.WriteString(fmt.Sprintf(`<text x="%g" y="24" font-size="16">%s</text>`+"\n", left, html.EscapeString(title)))
+
+	base := make([]float64, len(labels))
+	for s, band := range series {
+		if band.Outline {
+			continue
+		}
+		var upper, lower []string
+		for i := range labels {
+			upper = append(upper, fmt.Sprintf("%.1f,%.1f", x(i), y(base[i]+band.Values[i])))
+		}
+		for i := len(labels) - 1; i >= 0; i-- {
+			lower = append(lower, fmt.Sprintf("%.1f,%.1f", x(i), y(base[i])))
+			base[i] += band.Values[i]
+		}
+		color := chartColors[s%len(chartColors)]
+		sb.WriteString(fmt.Sprintf(`<polygon points="%s %s" fill="%s" stroke="%s"/>`+"\n", strings.Join(upper, " "), strings.Join(lower, " "), color, color))
+	}
+	for s, line := range series {
+		if !line.Outline {
+			continue
+		}
+		var points []string
+		for i := range labels {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(line.Values[i])))
+		}
+		sb.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), chartColors[s%len(chartColors)]))
+	}
+	for s, band := range series {
+		legendY := top + float64(len(series)-1-s)*20
+		sb.WriteString(fmt.Sprintf(`<rect x="%g" y="%g" width="12" height="12" fill="%s"/>`+"\n", width-right+20, legendY, chartColors[s%len(chartColors)]))
+		sb.WriteString(fmt.Sprintf(`<text x="%g" y="%g">%s</text>`+"\n", width-right+38, legendY+11, html.EscapeString(band.Name)))
+	}
+
+	sb.WriteString(fmt.Sprintf(`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="black"/>`+"\n", left, top, left, top+plotHeight))
+	sb.WriteString(fmt.Sprintf(`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="black"/>`+"\n", left, top+plotHeight, left+plotWidth, top+plotHeight))
+	sb.WriteString(fmt.Sprintf(`<text x="%g" y="%g" text-anchor="end">%g</text>`+"\n", left-6, top+4, math.Round(most*10)/10))
+	sb.WriteString(fmt.Sprintf(`<text x="%g" y="%g" text-anchor="end">0</text>`+"\n", left-6, top+plotHeight+4))
+	if len(labels) > 0 {
+		sb.WriteString(fmt.Sprintf(`<text x="%g" y="%g">%s</text>`+"\n", left, height-bottom+20, html.EscapeString(labels[0])))
+		sb.WriteString(fmt.Sprintf(`<text x="%g" y="%g" text-anchor="end">%s</text>`+"\n", left+plotWidth, height-bottom+20, html.EscapeString(labels[len(labels)-1])))
+	}
+	sb.WriteString("</svg>\n")
+
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
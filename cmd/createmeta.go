@@ -0,0 +1,239 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+var createMetaCache = map[string]*jira.MetaIssueType{}
+
+// getCreateMeta returns the fields that can be set when creating an issue of the given type.
+// Cloud has removed the expanded createmeta endpoint, so the issue types of the
+// project are listed first and then the fields of the matching type.
+func getCreateMeta(jiraClient *jira.Client, project string, issueType string) (*jira.MetaIssueType, error) {
+	cacheKey := strings.ToUpper(project) + "/" + strings.ToLower(issueType)
+	if meta, ok := createMetaCache[cacheKey]; ok {
+		return meta, nil
+	}
+
+	var issueTypes []jira.MetaIssueType
+	err := getCreateMetaPages(jiraClient, fmt.Sprintf("rest/api/2/issue/createmeta/%s/issuetypes", url.PathEscape(strings.ToUpper(project))), "issueTypes", func(raw json.RawMessage) error {
+		var page []jira.MetaIssueType
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return err
+		}
+		issueTypes = append(issueTypes, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("project %s doesn't exist or you can't create issues in it: %s", project, err)
+	}
+
+	var meta *jira.MetaIssueType
+	var names []string
+	for i := range issueTypes {
+		names = append(names, issueTypes[i].Name)
+		if strings.EqualFold(issueTypes[i].Name, issueType) {
+			meta = &issueTypes[i]
+		}
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("%s has no issue type %q, choose from: %s", project, issueType, strings.Join(names, ", "))
+	}
+
+	meta.Fields = map[string]interface{}{}
+	err = getCreateMetaPages(jiraClient, fmt.Sprintf("rest/api/2/issue/createmeta/%s/issuetypes/%s", url.PathEscape(strings.ToUpper(project)), meta.Id), "fields", func(raw json.RawMessage) error {
+		var page []map[string]interface{}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return err
+		}
+		for _, field := range page {
+			if id, _ := field["fieldId"].(string); id != "" {
+				meta.Fields[id] = field
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	createMetaCache[cacheKey] = meta
+	return meta, nil
+}
+
+// getCreateMetaPages requests every page of a createmeta list and passes the items
+// of each page to add. Cloud returns the items under listName while Server uses values.
+func getCreateMetaPages(jiraClient *jira.Client, endpoint string, listName string, add func(json.RawMessage) error) error {
+	startAt := 0
+	for {
+		req, err := jiraClient.NewRequest("GET", fmt.Sprintf("%s?startAt=%d", endpoint, startAt), nil)
+		if err != nil {
+			return err
+		}
+		var page map[string]json.RawMessage
+		if resp, err := jiraClient.Do(req, &page); err != nil {
+			return jira.NewJiraError(resp, err)
+		}
+		items, ok := page[listName]
+		if !ok {
+			items = page["values"]
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		var count []json.RawMessage
+		if err := json.Unmarshal(items, &count); err != nil {
+			return err
+		}
+		if err := add(items); err != nil {
+			return err
+		}
+
+		var total int
+		json.Unmarshal(page["total"], &total)
+		startAt += len(count)
+		if len(count) == 0 || startAt >= total {
+			return nil
+		}
+	}
+}
+
+// resolveCustomFields maps field display names (or ids) to field ids and converts
+// each value into the shape its schema expects
+func resolveCustomFields(jiraClient *jira.Client, meta *jira.MetaIssueType, values map[string]interface{}) (map[string]interface{}, error) {
+	resolved := map[string]interface{}{}
+	for name, value := range values {
+		id, field := findMetaField(meta, name)
+		if field == nil {
+			return nil, fmt.Errorf("%s has no field %q", meta.Name, name)
+		}
+		converted, err := convertFieldValue(jiraClient, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		resolved[id] = converted
+	}
+	return resolved, nil
+}
+
+func findMetaField(meta *jira.MetaIssueType, name string) (string, map[string]interface{}) {
+	var ids []string
+	for id := range meta.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		field, _ := meta.Fields[id].(map[string]interface{})
+		fieldName, _ := field["name"].(string)
+		if id == name || strings.EqualFold(fieldName, name) {
+			return id, field
+		}
+	}
+	return "", nil
+}
+
+// convertFieldValue turns a value given as text, or as a list of values, into the
+// JSON the field's schema expects. Values that are already structured, e.g. maps
+// from a template, are kept.
+func convertFieldValue(jiraClient *jira.Client, field map[string]interface{}, value interface{}) (interface{}, error) {
+	schema, _ := field["schema"].(map[string]interface{})
+	fieldType, _ := schema["type"].(string)
+	items, _ := schema["items"].(string)
+
+	if list, isList := value.([]interface{}); isList && fieldType == "array" {
+		converted := []interface{}{}
+		for _, item := range list {
+			text, isText := scalarText(item)
+			if !isText {
+				converted = append(converted, item)
+				continue
+			}
+			value, err := convertScalar(jiraClient, items, text)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, value)
+		}
+		return converted, nil
+	}
+
+	text, isText := scalarText(value)
+	if !isText {
+		return value, nil
+	}
+	if fieldType == "array" {
+		var converted []interface{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := convertScalar(jiraClient, items, item)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, value)
+		}
+		return converted, nil
+	}
+	return convertScalar(jiraClient, fieldType, text)
+}
+
+// scalarText returns a string, number or boolean as text, and false for anything structured
+func scalarText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func convertScalar(jiraClient *jira.Client, fieldType string, text string) (interface{}, error) {
+	switch fieldType {
+	case "number":
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return number, nil
+	case "option":
+		return map[string]string{"value": text}, nil
+	case "user":
+		user, err := lookupUser(jiraClient, text)
+		if err != nil {
+			return nil, err
+		}
+		// Server rejects users with more than the id or name set
+		return &jira.User{AccountID: user.AccountID, Name: user.Name}, nil
+	case "priority", "version", "component", "issuetype", "resolution":
+		return map[string]string{"name": text}, nil
+	case "json":
+		// sprints are set by id
+		if id, err := strconv.Atoi(text); err == nil {
+			return id, nil
+		}
+	}
+	return text, nil
+}
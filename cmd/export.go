@@ -0,0 +1,370 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 database/sql driver
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// exportState holds the high-water mark of incremental exports
+type exportState struct {
+	Projects map[string]time.Time `json:"projects"`
+}
+
+func exportStatePath(file string) string {
+	if file != "" {
+		return file
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(home, ".jira-tools-state.json")
+}
+
+func loadExportState(file string) exportState {
+	state := exportState{Projects: map[string]time.Time{}}
+	data, err := os.ReadFile(exportStatePath(file))
+	if os.IsNotExist(err) {
+		return state
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Fatalf("Couldn't read the export state file: %s", err)
+	}
+	if state.Projects == nil {
+		state.Projects = map[string]time.Time{}
+	}
+	return state
+}
+
+func saveExportState(file string, state exportState) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(exportStatePath(file), data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// update moves the project's high-water mark to the most recently updated issue
+func (s *exportState) update(project string, issues []jira.Issue) {
+	for _, issue := range issues {
+		if updated := time.Time(issue.Fields.Updated); updated.After(s.Projects[project]) {
+			s.Projects[project] = updated
+		}
+	}
+}
+
+// getExportFormat picks csv, ndjson or sqlite from the output file extension
+func getExportFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".db", ".sqlite", ".sqlite3":
+		return "sqlite"
+	}
+	return "csv"
+}
+
+// upsertRecords adds the records to the output file, replacing any existing
+// records with the same value in the key column
+func upsertRecords(path string, format string, header []string, records [][]string, keyColumn string) {
+	keyIndex := columnIndex(header, keyColumn)
+	if keyIndex < 0 {
+		log.Fatalf("The export has no %s column", keyColumn)
+	}
+
+	switch format {
+	case "sqlite":
+		upsertSQLite(path, header, records, keyIndex)
+	case "ndjson":
+		upsertNDJSON(path, header, records, keyIndex)
+	default:
+		upsertCSV(path, header, records, keyIndex)
+	}
+}
+
+// mergeRecords replaces existing rows that share a key with a new row and appends the rest
+func mergeRecords(existing [][]string, records [][]string, keyIndex int) [][]string {
+	position := map[string]int{}
+	for i, row := range existing {
+		position[row[keyIndex]] = i
+	}
+	for _, record := range records {
+		if i, ok := position[record[keyIndex]]; ok {
+			existing[i] = record
+			continue
+		}
+		position[record[keyIndex]] = len(existing)
+		existing = append(existing, record)
+	}
+	return existing
+}
+
+// unionColumns returns the existing columns followed by any new ones in header
+func unionColumns(existing []string, header []string) []string {
+	columns := append([]string{}, existing...)
+	seen := map[string]bool{}
+	for _, column := range existing {
+		seen[column] = true
+	}
+	for _, column := range header {
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// remapRows rearranges rows from one set of columns to another, leaving missing cells empty
+func remapRows(rows [][]string, from []string, to []string) [][]string {
+	index := map[string]int{}
+	for i, column := range from {
+		index[column] = i
+	}
+	remapped := make([][]string, len(rows))
+	for r, row := range rows {
+		remapped[r] = make([]string, len(to))
+		for i, column := range to {
+			if j, ok := index[column]; ok && j < len(row) {
+				remapped[r][i] = row[j]
+			}
+		}
+	}
+	return remapped
+}
+
+func upsertCSV(path string, header []string, records [][]string, keyIndex int) {
+	var existing [][]string
+	columns := header
+	if file, err := os.Open(path); err == nil {
+		csvReader := csv.NewReader(file)
+		// rows written before a column was added are shorter than the header
+		csvReader.FieldsPerRecord = -1
+		rows, err := csvReader.ReadAll()
+		file.Close()
+		if err != nil {
+			log.Fatalf("Couldn't read %s: %s", path, err)
+		}
+		if len(rows) > 0 {
+			// columns such as new SLAs may appear after the file was created
+			columns = unionColumns(rows[0], header)
+			existing = remapRows(rows[1:], rows[0], columns)
+		}
+	}
+
+	var sb strings.Builder
+	csvWriter := csv.NewWriter(&sb)
+	csvWriter.Write(columns)
+	csvWriter.WriteAll(mergeRecords(existing, remapRows(records, header, columns), columnIndex(columns, header[keyIndex])))
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal(err)
+	}
+	writeCSVToFile(sb.String(), path)
+}
+
+func upsertNDJSON(path string, header []string, records [][]string, keyIndex int) {
+	var objects []map[string]string
+	var existingColumns []string
+	seen := map[string]bool{}
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var object map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+				log.Fatalf("Couldn't read %s: %s", path, err)
+			}
+			var keys []string
+			for key := range object {
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			existingColumns = append(existingColumns, keys...)
+			objects = append(objects, object)
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// keep keys such as old SLAs that are no longer in this export
+	columns := unionColumns(header, existingColumns)
+	existing := make([][]string, len(objects))
+	for r, object := range objects {
+		existing[r] = make([]string, len(columns))
+		for i, column := range columns {
+			existing[r][i] = object[column]
+		}
+	}
+
+	createdFile, err := os.Create(path)
+	if err != nil {
+		log.Fatal("Cannot create file", err)
+	}
+	defer createdFile.Close()
+
+	encoder := json.NewEncoder(createdFile)
+	for _, row := range mergeRecords(existing, remapRows(records, header, columns), keyIndex) {
+		object := make(map[string]string, len(columns))
+		for i, column := range columns {
+			object[column] = row[i]
+		}
+		if err := encoder.Encode(object); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// columnIndex returns the position of column in columns, or -1
+func columnIndex(columns []string, column string) int {
+	for i, c := range columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func upsertSQLite(path string, header []string, records [][]string, keyIndex int) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	columns := make([]string, len(header))
+	placeholders := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = quoteIdentifier(column) + " TEXT"
+		if i == keyIndex {
+			columns[i] += " PRIMARY KEY"
+		}
+		placeholders[i] = "?"
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS issues (%s)", strings.Join(columns, ", "))); err != nil {
+		log.Fatal(err)
+	}
+
+	// columns such as new SLAs may appear after the table was created
+	existing, err := getTableColumns(db, "issues")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, column := range header {
+		if existing[strings.ToLower(column)] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE issues ADD COLUMN %s TEXT", quoteIdentifier(column))); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	quoted := make([]string, len(header))
+	for i, column := range header {
+		quoted[i] = quoteIdentifier(column)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// only this run's columns are updated so values in columns it doesn't have, such
+	// as an SLA that is no longer returned, are kept like the CSV and NDJSON upserts do
+	var updates []string
+	for i, column := range quoted {
+		if i != keyIndex {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+	conflict := "DO NOTHING"
+	if len(updates) > 0 {
+		conflict = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO issues (%s) VALUES (%s) ON CONFLICT(%s) %s",
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "), quoted[keyIndex], conflict))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for _, record := range records {
+		values := make([]interface{}, len(record))
+		for i, value := range record {
+			values[i] = value
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			tx.Rollback()
+			log.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// getTableColumns returns the lower cased names of the columns of a table, as
+// SQLite compares column names without regard to case
+func getTableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			dflt       sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &dflt, &primaryKey); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,244 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// Custom field schema types used by Jira Software
+const (
+	sprintFieldType   = "com.pyxis.greenhopper.jira:gh-sprint"
+	epicLinkFieldType = "com.pyxis.greenhopper.jira:gh-epic-link"
+
+	requestTypeFieldType   = "com.atlassian.servicedesk:vp-origin"
+	organizationsFieldType = "com.atlassian.servicedesk:sd-customer-organizations"
+)
+
+var jiraFields []jira.Field
+
+func getFields(jiraClient *jira.Client) []jira.Field {
+	fields, err := fetchFields(jiraClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return fields
+}
+
+// fetchFields is getFields returning an error instead of exiting
+func fetchFields(jiraClient *jira.Client) ([]jira.Field, error) {
+	if jiraFields == nil {
+		fields, resp, err := jiraClient.Field.GetList()
+		if err != nil {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		jiraFields = fields
+	}
+	return jiraFields, nil
+}
+
+// getCustomFieldID returns the id (e.g. customfield_10010) of the first custom field
+// with the given schema type, or with one of the given names. It returns an empty
+// string when no field matches.
+func getCustomFieldID(jiraClient *jira.Client, schemaType string, names ...string) string {
+	fieldID, err := findCustomFieldID(jiraClient, schemaType, names...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return fieldID
+}
+
+// findCustomFieldID is getCustomFieldID returning an error instead of exiting,
+// for callers such as --watch that keep running when a request fails
+func findCustomFieldID(jiraClient *jira.Client, schemaType string, names ...string) (string, error) {
+	fields, err := fetchFields(jiraClient)
+	if err != nil {
+		return "", err
+	}
+	for _, field := range fields {
+		if schemaType != "" && field.Schema.Custom == schemaType {
+			return field.ID, nil
+		}
+	}
+	for _, field := range fields {
+		for _, name := range names {
+			if strings.EqualFold(field.Name, name) {
+				return field.ID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// customFieldClause turns a field id such as customfield_10010 into the cf[10010]
+// form JQL accepts regardless of the field's display name
+func customFieldClause(fieldID string) string {
+	return "cf[" + strings.TrimPrefix(fieldID, "customfield_") + "]"
+}
+
+var serverSprintField = regexp.MustCompile(`(\w+)=([^,\]]*)`)
+
+// getIssueSprints returns the sprints an issue has been in, oldest first.
+// Cloud returns sprints as objects while Server returns them as encoded strings.
+func getIssueSprints(issue *jira.Issue, sprintFieldID string) []jira.Sprint {
+	var sprints []jira.Sprint
+	if issue.Fields == nil || sprintFieldID == "" {
+		return sprints
+	}
+	values, ok := issue.Fields.Unknowns[sprintFieldID].([]interface{})
+	if !ok {
+		return sprints
+	}
+	return parseSprints(values)
+}
+
+// parseSprints reads the values of a sprint field
+func parseSprints(values []interface{}) []jira.Sprint {
+	var sprints []jira.Sprint
+	for _, value := range values {
+		fields := map[string]string{}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, field := range v {
+				switch f := field.(type) {
+				case string:
+					fields[key] = f
+				case float64:
+					fields[key] = strconv.FormatFloat(f, 'f', -1, 64)
+				}
+			}
+		case string:
+			// e.g. com.atlassian.greenhopper.service.sprint.Sprint@1f39[id=1,state=ACTIVE,name=Sprint 1,...]
+			if open := strings.Index(v, "["); open >= 0 {
+				v = v[open:]
+			}
+			for _, match := range serverSprintField.FindAllStringSubmatch(v, -1) {
+				if match[2] != "<null>" {
+					fields[match[1]] = match[2]
+				}
+			}
+		default:
+			continue
+		}
+		if fields["name"] == "" {
+			continue
+		}
+		id, _ := strconv.Atoi(fields["id"])
+		sprints = append(sprints, jira.Sprint{
+			ID:           id,
+			Name:         fields["name"],
+			State:        strings.ToLower(fields["state"]),
+			StartDate:    sprintFieldTime(fields["startDate"]),
+			EndDate:      sprintFieldTime(fields["endDate"]),
+			CompleteDate: sprintFieldTime(fields["completeDate"]),
+		})
+	}
+	return sprints
+}
+
+func sprintFieldTime(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// getSprintNames returns the names of the sprints an issue has been in, oldest first
+func getSprintNames(issue *jira.Issue, sprintFieldID string) []string {
+	var names []string
+	for _, sprint := range getIssueSprints(issue, sprintFieldID) {
+		names = append(names, sprint.Name)
+	}
+	return names
+}
+
+// getEpicKey returns the key of the epic an issue belongs to, either through the
+// Epic Link field or through its parent on next-gen projects
+func getEpicKey(issue *jira.Issue, epicLinkFieldID string) string {
+	if issue.Fields == nil {
+		return ""
+	}
+	if epicLinkFieldID != "" {
+		if key, ok := issue.Fields.Unknowns[epicLinkFieldID].(string); ok && key != "" {
+			return key
+		}
+	}
+	if issue.Fields.Epic != nil {
+		return issue.Fields.Epic.Key
+	}
+	if issue.Fields.Parent != nil && !issue.Fields.Type.Subtask {
+		return issue.Fields.Parent.Key
+	}
+	return ""
+}
+
+// getRequestTypeName returns the service desk request type of an issue
+func getRequestTypeName(issue *jira.Issue, requestTypeFieldID string) string {
+	if issue.Fields == nil || requestTypeFieldID == "" {
+		return ""
+	}
+	origin, ok := issue.Fields.Unknowns[requestTypeFieldID].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	requestType, ok := origin["requestType"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := requestType["name"].(string)
+	return name
+}
+
+// getOrganizationNames returns the customer organisations of a service desk issue
+func getOrganizationNames(issue *jira.Issue, organizationsFieldID string) []string {
+	var names []string
+	if issue.Fields == nil || organizationsFieldID == "" {
+		return names
+	}
+	organizations, ok := issue.Fields.Unknowns[organizationsFieldID].([]interface{})
+	if !ok {
+		return names
+	}
+	for _, organization := range organizations {
+		if o, ok := organization.(map[string]interface{}); ok {
+			if name, ok := o["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// getStoryPointsFieldID returns the id of the story points field, which is named
+// "Story point estimate" on next-gen projects
+func getStoryPointsFieldID(jiraClient *jira.Client) string {
+	return getCustomFieldID(jiraClient, "", "Story Points", "Story point estimate")
+}
+
+// getStoryPoints returns an issue's story points, or 0 when it isn't estimated
+func getStoryPoints(issue *jira.Issue, storyPointsFieldID string) float64 {
+	if issue.Fields == nil || storyPointsFieldID == "" {
+		return 0
+	}
+	points, _ := issue.Fields.Unknowns[storyPointsFieldID].(float64)
+	return points
+}
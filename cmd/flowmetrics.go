@@ -0,0 +1,268 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/spf13/cobra"
+)
+
+// IssueFlow holds the flow metrics of a single completed issue
+type IssueFlow struct {
+	Issue        jira.Issue
+	Created      time.Time
+	Started      time.Time
+	Done         time.Time
+	LeadTime     time.Duration
+	CycleTime    time.Duration
+	TimeInStatus map[string]time.Duration
+}
+
+// FlowFormat is the output format: table or csv
+var FlowFormat string
+
+// flowCmd represents the metrics flow command
+var flowCmd = &cobra.Command{
+	Use:   "flow",
+	Short: "Lead time, cycle time, time in status and throughput",
+	Long: `Reads the changelogs of completed issues to calculate:
+
+  lead time       created to done
+  cycle time      first moved to an in progress status to done
+  time in status  how long issues spent in each status
+  throughput      issues completed per week
+
+The table output includes percentiles and a cycle time histogram. The csv
+output has one row per issue for further analysis in a spreadsheet.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if FlowFormat != "table" && FlowFormat != "csv" {
+			log.Fatal("--format must be table or csv")
+		}
+		query := makeMetricsQuery().Eq("statusCategory", "Done").OrderBy("resolved", false)
+
+		jiraClient, _ := jirasetup.GetJiraClient()
+		categories := getStatusCategories(jiraClient)
+		issues := searchAllIssues(jiraClient, query.String(), &jira.SearchOptions{
+			Expand: "changelog",
+			Fields: []string{"summary", "issuetype", "status", "created", "resolutiondate"},
+		})
+
+		var flows []IssueFlow
+		for _, issue := range issues {
+			flows = append(flows, getIssueFlow(issue, categories))
+		}
+
+		if FlowFormat == "csv" {
+			writeFlowCSV(flows)
+		} else {
+			printFlowTable(flows, getProfileLocation(jiraClient))
+		}
+	},
+}
+
+func init() {
+	metricsCmd.AddCommand(flowCmd)
+
+	flowCmd.Flags().StringVar(&FlowFormat, "format", "table", "output format: table or csv")
+}
+
+// getIssueFlow walks an issue's status history to work out its lead time, cycle time and time in each status
+func getIssueFlow(issue jira.Issue, categories map[string]string) IssueFlow {
+	flow := IssueFlow{
+		Issue:        issue,
+		Created:      time.Time(issue.Fields.Created),
+		TimeInStatus: map[string]time.Duration{},
+	}
+
+	changes := getStatusChanges(&issue)
+	_, status := getInitialStatus(&issue, changes)
+	enteredAt := flow.Created
+	for _, change := range changes {
+		flow.TimeInStatus[status] += change.At.Sub(enteredAt)
+		status, enteredAt = change.To, change.At
+
+		switch categories[change.ToID] {
+		case "indeterminate":
+			if flow.Started.IsZero() {
+				flow.Started = change.At
+			}
+		case "done":
+			flow.Done = change.At
+		}
+	}
+
+	if flow.Done.IsZero() {
+		flow.Done = time.Time(issue.Fields.Resolutiondate)
+	}
+	if flow.Done.IsZero() {
+		flow.Done = enteredAt
+	}
+	// issues that skipped straight to done have no cycle time of their own
+	if flow.Started.IsZero() || flow.Started.After(flow.Done) {
+		flow.Started = flow.Done
+	}
+
+	flow.LeadTime = flow.Done.Sub(flow.Created)
+	flow.CycleTime = flow.Done.Sub(flow.Started)
+	return flow
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
+
+// getFlowStatuses returns every status the issues passed through, longest total time first
+func getFlowStatuses(flows []IssueFlow) []string {
+	totals := map[string]time.Duration{}
+	for _, flow := range flows {
+		for status, d := range flow.TimeInStatus {
+			totals[status] += d
+		}
+	}
+	statuses := make([]string, 0, len(totals))
+	for status := range totals {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return totals[statuses[i]] > totals[statuses[j]] })
+	return statuses
+}
+
+func writeFlowCSV(flows []IssueFlow) {
+	statuses := getFlowStatuses(flows)
+	csvWriter := csv.NewWriter(os.Stdout)
+
+	header := []string{"Key", "Type", "Summary", "Created", "Started", "Done", "Lead Time (days)", "Cycle Time (days)"}
+	for _, status := range statuses {
+		header = append(header, status+" (days)")
+	}
+	csvWriter.Write(header)
+
+	for _, flow := range flows {
+		record := []string{
+			flow.Issue.Key,
+			flow.Issue.Fields.Type.Name,
+			flow.Issue.Fields.Summary,
+			flow.Created.Format(time.RFC3339),
+			flow.Started.Format(time.RFC3339),
+			flow.Done.Format(time.RFC3339),
+			fmt.Sprintf("%.2f", days(flow.LeadTime)),
+			fmt.Sprintf("%.2f", days(flow.CycleTime)),
+		}
+		for _, status := range statuses {
+			record = append(record, fmt.Sprintf("%.2f", days(flow.TimeInStatus[status])))
+		}
+		csvWriter.Write(record)
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printFlowTable prints the percentiles, histogram and weekly throughput, counting
+// weeks in the given time zone
+func printFlowTable(flows []IssueFlow, location *time.Location) {
+	if len(flows) == 0 {
+		fmt.Println("No completed issues found")
+		return
+	}
+
+	var leadTimes, cycleTimes []time.Duration
+	throughput := map[string]int{}
+	for _, flow := range flows {
+		leadTimes = append(leadTimes, flow.LeadTime)
+		cycleTimes = append(cycleTimes, flow.CycleTime)
+		throughput[weekStart(flow.Done.In(location)).Format("2006-01-02")]++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Completed issues\t%d\n\n", len(flows))
+
+	fmt.Fprintln(w, "\t50%\t85%\t95%")
+	fmt.Fprintf(w, "Lead time\t%s\t%s\t%s\n", formatDuration(percentile(leadTimes, 50)), formatDuration(percentile(leadTimes, 85)), formatDuration(percentile(leadTimes, 95)))
+	fmt.Fprintf(w, "Cycle time\t%s\t%s\t%s\n\n", formatDuration(percentile(cycleTimes, 50)), formatDuration(percentile(cycleTimes, 85)), formatDuration(percentile(cycleTimes, 95)))
+
+	fmt.Fprintln(w, "Status\tAverage time")
+	for _, status := range getFlowStatuses(flows) {
+		var total time.Duration
+		for _, flow := range flows {
+			total += flow.TimeInStatus[status]
+		}
+		fmt.Fprintf(w, "%s\t%s\n", status, formatDuration(total/time.Duration(len(flows))))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Week\tCompleted")
+	weeks := make([]string, 0, len(throughput))
+	for week := range throughput {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	for _, week := range weeks {
+		fmt.Fprintf(w, "%s\t%d\n", week, throughput[week])
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println("Cycle time (days)")
+	fmt.Print(formatHistogram(cycleTimes, 10, 40))
+}
+
+// formatHistogram draws the durations in day-wide buckets, widening the buckets
+// so there are no more than maxBuckets of them
+func formatHistogram(durations []time.Duration, maxBuckets int, width int) string {
+	var longest time.Duration
+	for _, d := range durations {
+		if d > longest {
+			longest = d
+		}
+	}
+	bucketDays := int(math.Ceil(days(longest) / float64(maxBuckets)))
+	if bucketDays < 1 {
+		bucketDays = 1
+	}
+	buckets := make([]int, int(days(longest))/bucketDays+1)
+	for _, d := range durations {
+		buckets[int(days(d))/bucketDays]++
+	}
+
+	most := 0
+	for _, count := range buckets {
+		if count > most {
+			most = count
+		}
+	}
+
+	var sb strings.Builder
+	for i, count := range buckets {
+		bar := 0
+		if most > 0 {
+			bar = int(math.Round(float64(count) / float64(most) * float64(width)))
+		}
+		sb.WriteString(fmt.Sprintf("%4d-%-4d %-*s %d\n", i*bucketDays, (i+1)*bucketDays, width, strings.Repeat("#", bar), count))
+	}
+	return sb.String()
+}
@@ -0,0 +1,180 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/patrickjmcd/jira-tools/markup"
+	"github.com/spf13/cobra"
+)
+
+var issueKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// issueCmd represents the issue command
+var issueCmd = &cobra.Command{
+	Use:   "issue",
+	Short: "Create and change issues",
+}
+
+func init() {
+	rootCmd.AddCommand(issueCmd)
+}
+
+// readTextArg returns the text of a flag that takes either a value, @file to read
+// the value from a file, or - to read it from standard input
+func readTextArg(value string) string {
+	var data []byte
+	var err error
+	switch {
+	case value == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(value, "@"):
+		data, err = os.ReadFile(strings.TrimPrefix(value, "@"))
+	default:
+		return value
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(data)
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// editText opens initial in $VISUAL or $EDITOR and returns the saved text. The
+// suffix, such as .md, lets editors pick the right syntax highlighting.
+func editText(initial string, suffix string) string {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "jira-tools-*"+suffix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(initial); err != nil {
+		log.Fatal(err)
+	}
+	file.Close()
+
+	// the editor may include arguments, e.g. "code --wait"
+	editCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", file.Name())
+	editCmd.Stdin, editCmd.Stdout, editCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := editCmd.Run(); err != nil {
+		log.Fatalf("The editor failed: %s", err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return string(data)
+}
+
+// findUser resolves "me", an account id, a username, an email address or a display
+// name to a user that can be set on an issue
+func findUser(jiraClient *jira.Client, who string) *jira.User {
+	user, err := lookupUser(jiraClient, who)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &jira.User{AccountID: user.AccountID, Name: user.Name}
+}
+
+// lookupUser is findUser returning an error instead of exiting when no single user matches
+func lookupUser(jiraClient *jira.Client, who string) (*jira.User, error) {
+	if strings.EqualFold(who, "me") {
+		self, _, err := jiraClient.User.GetSelf()
+		return self, err
+	}
+
+	users, _, err := jiraClient.User.Find(url.QueryEscape(who))
+	if err != nil || len(users) == 0 {
+		// Jira Server searches users by username rather than query
+		users, _, err = jiraClient.User.Find("", jira.WithUsername(url.QueryEscape(who)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, user := range users {
+		if user.AccountID == who || strings.EqualFold(user.Name, who) || strings.EqualFold(user.EmailAddress, who) || strings.EqualFold(user.DisplayName, who) {
+			return &users[i], nil
+		}
+	}
+	switch len(users) {
+	case 0:
+		return nil, fmt.Errorf("no user matches %q", who)
+	case 1:
+		return &users[0], nil
+	}
+	return nil, fmt.Errorf("more than one user matches %q, use their email address or account id", who)
+}
+
+// mentionResolver looks up @mentions, using account ids on Cloud and usernames on Server
+func mentionResolver(jiraClient *jira.Client, cloud bool) markup.MentionFunc {
+	return func(name string) (string, string, bool) {
+		user, err := lookupUser(jiraClient, name)
+		if err != nil {
+			return "", "", false
+		}
+		if cloud {
+			return user.AccountID, user.DisplayName, true
+		}
+		return user.Name, user.DisplayName, true
+	}
+}
+
+var cloudDeployment *bool
+
+// isCloud tells whether the server is Jira Cloud, which uses account ids and
+// Atlassian Document Format, rather than Jira Server or Data Center
+func isCloud(jiraClient *jira.Client) bool {
+	if cloudDeployment == nil {
+		req, err := jiraClient.NewRequest("GET", "rest/api/2/serverInfo", nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var info struct {
+			DeploymentType string `json:"deploymentType"`
+		}
+		if resp, err := jiraClient.Do(req, &info); err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+		cloud := strings.EqualFold(info.DeploymentType, "Cloud")
+		cloudDeployment = &cloud
+	}
+	return *cloudDeployment
+}
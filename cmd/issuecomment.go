@@ -0,0 +1,224 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/markup"
+	"github.com/spf13/cobra"
+)
+
+// issueComment is a comment with the properties that mark service desk comments internal
+type issueComment struct {
+	ID         string    `json:"id"`
+	Author     jira.User `json:"author"`
+	Body       string    `json:"body"`
+	Created    string    `json:"created"`
+	Properties []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"properties"`
+}
+
+// internal tells whether the comment is only visible to service desk agents
+func (c issueComment) internal() bool {
+	for _, property := range c.Properties {
+		if property.Key == "sd.public.comment" {
+			internal, _ := property.Value["internal"].(bool)
+			return internal
+		}
+	}
+	return false
+}
+
+// CommentFile is a markdown file to read the comment from
+var CommentFile string
+
+// CommentInternal makes the comment visible only to service desk agents
+var CommentInternal bool
+
+// CommentList lists the existing comments instead of adding one
+var CommentList bool
+
+// issueCommentCmd represents the issue comment command
+var issueCommentCmd = &cobra.Command{
+	Use:   "comment KEY [TEXT...]",
+	Short: "Adds a markdown comment to an issue, or lists its comments",
+	Long: `Adds a comment written in markdown. The text comes from the arguments, from
+--file, or from $EDITOR when neither is given. It is converted to Atlassian
+Document Format on Jira Cloud and to wiki markup on Jira Server.
+
+@name mentions a user by username, email address or name. On service desk
+issues --internal hides the comment from customers; other issues refuse it.
+
+Headings, paragraphs, lists, code blocks, quotes, rules, bold, italic,
+strikethrough, inline code and links are converted.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToUpper(args[0])
+		jiraClient, _ := jirasetup.GetJiraClient()
+
+		if CommentList {
+			comments := getComments(jiraClient, key)
+			printComments(comments, getCommentText(jiraClient, key, comments))
+			return
+		}
+
+		text := strings.Join(args[1:], " ")
+		switch CommentFile {
+		case "":
+		case "-":
+			text = readTextArg("-")
+		default:
+			text = readTextArg("@" + CommentFile)
+		}
+		if text == "" {
+			text = editText("", ".md")
+		}
+		if strings.TrimSpace(text) == "" {
+			log.Fatal("The comment is empty, nothing was added")
+		}
+
+		if err := addComment(jiraClient, key, text, CommentInternal); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Commented on %s\n", key)
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueCommentCmd)
+
+	issueCommentCmd.Flags().StringVarP(&CommentFile, "file", "F", "", "markdown file to read the comment from (- for stdin)")
+	issueCommentCmd.Flags().BoolVar(&CommentInternal, "internal", false, "only show the comment to service desk agents")
+	issueCommentCmd.Flags().BoolVarP(&CommentList, "list", "l", false, "list the issue's comments")
+}
+
+// isServiceDeskIssue tells whether the issue belongs to a service desk project
+func isServiceDeskIssue(jiraClient *jira.Client, key string) (bool, error) {
+	req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s?fields=project", key), nil)
+	if err != nil {
+		return false, err
+	}
+	var issue struct {
+		Fields struct {
+			Project struct {
+				ProjectTypeKey string `json:"projectTypeKey"`
+			} `json:"project"`
+		} `json:"fields"`
+	}
+	if resp, err := jiraClient.Do(req, &issue); err != nil {
+		return false, jira.NewJiraError(resp, err)
+	}
+	return issue.Fields.Project.ProjectTypeKey == "service_desk", nil
+}
+
+// addComment converts the markdown and posts it with the v3 API on Cloud or the v2 API on Server
+func addComment(jiraClient *jira.Client, key string, markdown string, internal bool) error {
+	cloud := isCloud(jiraClient)
+	mentions := mentionResolver(jiraClient, cloud)
+
+	comment := map[string]interface{}{}
+	if internal {
+		serviceDesk, err := isServiceDeskIssue(jiraClient, key)
+		if err != nil {
+			return err
+		}
+		if !serviceDesk {
+			return fmt.Errorf("%s isn't a service desk issue, only service desk comments can be internal", key)
+		}
+		comment["properties"] = []interface{}{map[string]interface{}{
+			"key":   "sd.public.comment",
+			"value": map[string]bool{"internal": true},
+		}}
+	}
+	endpoint := fmt.Sprintf("rest/api/2/issue/%s/comment", key)
+	if cloud {
+		endpoint = fmt.Sprintf("rest/api/3/issue/%s/comment", key)
+		comment["body"] = markup.ToADF(markdown, mentions)
+	} else {
+		comment["body"] = markup.ToWiki(markdown, mentions)
+	}
+
+	req, err := jiraClient.NewRequest("POST", endpoint, comment)
+	if err != nil {
+		return err
+	}
+	if resp, err := jiraClient.Do(req, nil); err != nil {
+		return jira.NewJiraError(resp, err)
+	}
+	return nil
+}
+
+func getComments(jiraClient *jira.Client, key string) []issueComment {
+	var comments []issueComment
+	for {
+		req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/comment?expand=properties&startAt=%d", key, len(comments)), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var page struct {
+			Total    int            `json:"total"`
+			Comments []issueComment `json:"comments"`
+		}
+		if resp, err := jiraClient.Do(req, &page); err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+		comments = append(comments, page.Comments...)
+		if len(page.Comments) == 0 || len(comments) >= page.Total {
+			return comments
+		}
+	}
+}
+
+// getCommentText renders the comments to plain text the same way issue view does,
+// keyed by comment id
+func getCommentText(jiraClient *jira.Client, key string, comments []issueComment) map[string]string {
+	issue := &jira.Issue{Key: key, Fields: &jira.IssueFields{Comments: &jira.Comments{}}}
+	for _, comment := range comments {
+		issue.Fields.Comments.Comments = append(issue.Fields.Comments.Comments, &jira.Comment{ID: comment.ID, Body: comment.Body})
+	}
+	return getRichText(jiraClient, issue).Comments
+}
+
+func printComments(comments []issueComment, text map[string]string) {
+	if len(comments) == 0 {
+		fmt.Println("No comments")
+		return
+	}
+	for _, comment := range comments {
+		created := comment.Created
+		if t, err := parseJiraTime(comment.Created); err == nil {
+			created = t.Local().Format("2006-01-02 15:04")
+		}
+		heading := fmt.Sprintf("%s, %s", comment.Author.DisplayName, created)
+		if comment.internal() {
+			color.Yellow("%s (internal)", heading)
+		} else {
+			color.Cyan("%s", heading)
+		}
+		body, ok := text[comment.ID]
+		if !ok {
+			body = markup.WikiToText(comment.Body)
+		}
+		fmt.Printf("%s\n\n", strings.TrimSpace(body))
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/patrickjmcd/jira-tools/markup"
+	"github.com/spf13/cobra"
+)
+
+// CreateProject is the project to create the issue in
+var CreateProject string
+
+// CreateType is the issue type to create
+var CreateType string
+
+// CreateSummary is the summary of the new issue
+var CreateSummary string
+
+// CreateDescription is the description, or @file to read it from a file
+var CreateDescription string
+
+// CreateAssignee is the user to assign the new issue to
+var CreateAssignee string
+
+// CreateLabels is a comma separated list of labels
+var CreateLabels string
+
+// CreateComponents is a comma separated list of components
+var CreateComponents string
+
+// CreateParent is the key of the parent issue or epic
+var CreateParent string
+
+// CreateFields holds Name=value pairs for other fields
+var CreateFields []string
+
+// CreateTemplate is the name of a template to start from
+var CreateTemplate string
+
+// CreateEdit opens the description in $EDITOR before creating the issue
+var CreateEdit bool
+
+// issueCreateCmd represents the issue create command
+var issueCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Creates an issue",
+	Long: `Creates an issue from flags, a template, or both. Flags override the values
+in the template.
+
+Templates are YAML files in the .jira-tools-templates directory next to the
+config file, e.g. ~/.jira-tools-templates/bug.yaml:
+
+  project: ABC
+  type: Bug
+  labels: [triage]
+  description: |
+    Steps to reproduce:
+  fields:
+    Severity: High
+
+Other fields are set by display name, e.g. --field "Story Points=3". Their
+values are converted to what the field expects using the project's create
+metadata.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		spec := IssueTemplate{}
+		if CreateTemplate != "" {
+			spec = loadIssueTemplate(CreateTemplate)
+		}
+		applyCreateFlags(&spec)
+		if CreateEdit {
+			spec.Description = editText(spec.Description, ".md")
+		}
+
+		jiraClient, url := jirasetup.GetJiraClient()
+		issue, err := buildIssue(jiraClient, spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		created, _, err := jiraClient.Issue.Create(issue)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created %s %s\n", created.Key, browseURL(url, created.Key))
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueCreateCmd)
+
+	issueCreateCmd.Flags().StringVarP(&CreateProject, "project", "p", "", "project key")
+	issueCreateCmd.Flags().StringVarP(&CreateType, "type", "t", "", "issue type (default \"Task\")")
+	issueCreateCmd.Flags().StringVarP(&CreateSummary, "summary", "s", "", "summary")
+	issueCreateCmd.Flags().StringVarP(&CreateDescription, "description", "d", "", "description, @file to read it from a file or - for stdin")
+	issueCreateCmd.Flags().StringVarP(&CreateAssignee, "assignee", "a", "", "assignee: me, an email address, username or account id")
+	issueCreateCmd.Flags().StringVarP(&CreateLabels, "labels", "l", "", "comma-separated list of labels")
+	issueCreateCmd.Flags().StringVarP(&CreateComponents, "components", "c", "", "comma-separated list of components")
+	issueCreateCmd.Flags().StringVar(&CreateParent, "parent", "", "key of the parent issue or epic")
+	issueCreateCmd.Flags().StringArrayVarP(&CreateFields, "field", "f", nil, "other field as \"Name=value\", may be repeated")
+	issueCreateCmd.Flags().StringVar(&CreateTemplate, "template", "", "template name or path to start from")
+	issueCreateCmd.Flags().BoolVarP(&CreateEdit, "edit", "e", false, "edit the description in $EDITOR")
+}
+
+// applyCreateFlags overrides the template's values with the flags that were given
+func applyCreateFlags(spec *IssueTemplate) {
+	if CreateProject != "" {
+		spec.Project = CreateProject
+	}
+	if CreateType != "" {
+		spec.Type = CreateType
+	}
+	if CreateSummary != "" {
+		spec.Summary = CreateSummary
+	}
+	if CreateDescription != "" {
+		spec.Description = readTextArg(CreateDescription)
+	}
+	if CreateAssignee != "" {
+		spec.Assignee = CreateAssignee
+	}
+	if CreateLabels != "" {
+		spec.Labels = jql.Split(CreateLabels)
+	}
+	if CreateComponents != "" {
+		spec.Components = jql.Split(CreateComponents)
+	}
+	if CreateParent != "" {
+		spec.Parent = CreateParent
+	}
+	for _, field := range CreateFields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("--field %q must look like Name=value", field)
+		}
+		if spec.Fields == nil {
+			spec.Fields = map[string]interface{}{}
+		}
+		spec.Fields[strings.TrimSpace(parts[0])] = parts[1]
+	}
+}
+
+// buildIssue turns a template into an issue ready to create, resolving the
+// assignee and the other fields by name
+func buildIssue(jiraClient *jira.Client, spec IssueTemplate) (*jira.Issue, error) {
+	if spec.Project == "" {
+		return nil, fmt.Errorf("a project is required")
+	}
+	if strings.TrimSpace(spec.Summary) == "" {
+		return nil, fmt.Errorf("a summary is required")
+	}
+	if spec.Type == "" {
+		spec.Type = "Task"
+	}
+
+	fields := &jira.IssueFields{
+		Project:     jira.Project{Key: strings.ToUpper(spec.Project)},
+		Type:        jira.IssueType{Name: spec.Type},
+		Summary:     spec.Summary,
+		Description: markdownToWiki(jiraClient, spec.Description),
+		Labels:      spec.Labels,
+	}
+	for _, component := range spec.Components {
+		fields.Components = append(fields.Components, &jira.Component{Name: component})
+	}
+	if spec.Parent != "" {
+		fields.Parent = &jira.Parent{Key: spec.Parent}
+	}
+	if spec.Assignee != "" {
+		fields.Assignee = findUser(jiraClient, spec.Assignee)
+	}
+	if len(spec.Fields) > 0 {
+		meta, err := getCreateMeta(jiraClient, spec.Project, spec.Type)
+		if err != nil {
+			return nil, err
+		}
+		custom, err := resolveCustomFields(jiraClient, meta, spec.Fields)
+		if err != nil {
+			return nil, err
+		}
+		fields.Unknowns = custom
+	}
+	return &jira.Issue{Fields: fields}, nil
+}
+
+// markdownToWiki converts markdown into wiki markup for fields set with the v2 API,
+// which takes wiki markup on both Cloud and Server. Cloud mentions are by account id.
+func markdownToWiki(jiraClient *jira.Client, markdown string) string {
+	if strings.TrimSpace(markdown) == "" {
+		return markdown
+	}
+	cloud := isCloud(jiraClient)
+	mentions := mentionResolver(jiraClient, cloud)
+	return markup.ToWiki(markdown, func(name string) (string, string, bool) {
+		id, display, ok := mentions(name)
+		if ok && cloud {
+			id = "accountid:" + id
+		}
+		return id, display, ok
+	})
+}
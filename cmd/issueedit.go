@@ -0,0 +1,463 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// EditUndoLog holds the values the edited fields had before an edit, so it can be undone
+type EditUndoLog struct {
+	Created time.Time       `json:"created"`
+	JQL     string          `json:"jql"`
+	Issues  []EditUndoEntry `json:"issues"`
+}
+
+// EditUndoEntry is the previous value of each edited field of one issue
+type EditUndoEntry struct {
+	Key    string                 `json:"key"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// EditResult is the outcome of editing one issue
+type EditResult struct {
+	Key string
+	Err error
+}
+
+// EditJQL selects the issues to edit
+var EditJQL string
+
+// EditSet holds field=value pairs for system fields such as priority or assignee
+var EditSet []string
+
+// EditSetField holds Name=value pairs for fields given by their display name
+var EditSetField []string
+
+// EditAddLabels are labels to add
+var EditAddLabels []string
+
+// EditRemoveLabels are labels to remove
+var EditRemoveLabels []string
+
+// EditUndoFile is where the undo log is written
+var EditUndoFile string
+
+// EditUndo is an undo log to restore the previous values from
+var EditUndo string
+
+// EditYes skips the confirmation prompt
+var EditYes bool
+
+// EditConcurrency is how many issues to edit at once
+var EditConcurrency int
+
+// EditRate is the most requests to send per second
+var EditRate float64
+
+var editMetaCache = map[string]*jira.MetaIssueType{}
+
+// editFieldsCache holds the values resolved against each edit screen in editMetaCache
+var editFieldsCache = map[string]map[string]interface{}{}
+
+// issueEditCmd represents the issue edit command
+var issueEditCmd = &cobra.Command{
+	Use:   "edit [KEY...]",
+	Short: "Edits fields on one or many issues",
+	Long: `Edits fields on the given issues, or on every issue matching --jql:
+
+  jira-tools issue edit --jql "project = ABC AND labels = triage" --set priority=High \
+    --add-label reviewed --remove-label triage --set-field "Team=Core"
+
+--set takes field ids or names, --set-field takes the names shown in Jira. The
+change set is previewed and confirmed before it is applied. The previous
+values are written to a JSON undo log first, and
+
+  jira-tools issue edit --undo jira-tools-undo-20240501-150405.json
+
+puts them back.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+
+		if EditUndo != "" {
+			undoEdit(jiraClient, EditUndo)
+			return
+		}
+
+		query := EditJQL
+		if query == "" {
+			var keys []string
+			for _, arg := range args {
+				keys = append(keys, strings.ToUpper(arg))
+			}
+			if len(keys) == 0 {
+				log.Fatal("Give the issues to edit as keys or with --jql")
+			}
+			query = jql.New().In("key", keys...).String()
+		}
+		values, err := getEditValues()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		issues := searchAllIssues(jiraClient, query, &jira.SearchOptions{Fields: []string{"summary", "status", "issuetype", "project"}})
+		if len(issues) == 0 {
+			fmt.Println("No issues match the query")
+			return
+		}
+		payloads := map[string]map[string]interface{}{}
+		fieldIDs := map[string]bool{}
+		for _, issue := range issues {
+			payload, err := getEditPayload(jiraClient, issue, values)
+			if err != nil {
+				log.Fatalf("%s: %s", issue.Key, err)
+			}
+			payloads[issue.Key] = payload
+			for id := range payload["fields"].(map[string]interface{}) {
+				fieldIDs[id] = true
+			}
+		}
+		if len(EditAddLabels) > 0 || len(EditRemoveLabels) > 0 {
+			fieldIDs["labels"] = true
+		}
+
+		printEditPlan(issues, values)
+		if !EditYes && !confirm(fmt.Sprintf("Edit these %d issues?", len(issues))) {
+			return
+		}
+
+		undo := EditUndoLog{Created: time.Now(), JQL: query, Issues: getPreviousValues(jiraClient, issues, fieldIDs)}
+		if err := writeUndoLog(EditUndoFile, undo); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Previous values saved to %s\n", EditUndoFile)
+
+		results := make([]EditResult, len(issues))
+		forEachConcurrently(len(issues), EditConcurrency, EditRate, func(i int) {
+			key := issues[i].Key
+			results[i] = EditResult{Key: key, Err: updateIssue(jiraClient, key, payloads[key])}
+		})
+		printEditResults(results, "edited")
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueEditCmd)
+
+	issueEditCmd.Flags().StringVarP(&EditJQL, "jql", "q", "", "edit every issue matching this query")
+	issueEditCmd.Flags().StringArrayVar(&EditSet, "set", nil, "field to set as \"field=value\", may be repeated")
+	issueEditCmd.Flags().StringArrayVar(&EditSetField, "set-field", nil, "field to set by its display name as \"Name=value\", may be repeated")
+	issueEditCmd.Flags().StringArrayVar(&EditAddLabels, "add-label", nil, "label to add, may be repeated")
+	issueEditCmd.Flags().StringArrayVar(&EditRemoveLabels, "remove-label", nil, "label to remove, may be repeated")
+	issueEditCmd.Flags().StringVar(&EditUndoFile, "undo-log", fmt.Sprintf("jira-tools-undo-%s.json", time.Now().Format("20060102-150405")), "file to write the previous values to")
+	issueEditCmd.Flags().StringVar(&EditUndo, "undo", "", "restore the previous values from an undo log")
+	issueEditCmd.Flags().BoolVarP(&EditYes, "yes", "y", false, "don't ask for confirmation")
+	issueEditCmd.Flags().IntVar(&EditConcurrency, "concurrency", 4, "number of issues to edit at once")
+	issueEditCmd.Flags().Float64Var(&EditRate, "rate", 10, "most requests to send per second, 0 for no limit")
+}
+
+// getEditValues collects the --set and --set-field values by field name
+func getEditValues() (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, field := range append(append([]string{}, EditSet...), EditSetField...) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q must look like Name=value", field)
+		}
+		values[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	if len(values) == 0 && len(EditAddLabels) == 0 && len(EditRemoveLabels) == 0 {
+		return nil, fmt.Errorf("nothing to change, use --set, --set-field, --add-label or --remove-label")
+	}
+	return values, nil
+}
+
+// getEditMeta returns the fields that can be edited on the issue. Issues of the
+// same project and type share their edit screen, so it is fetched once per pair.
+func getEditMeta(jiraClient *jira.Client, issue jira.Issue) (*jira.MetaIssueType, error) {
+	cacheKey := editMetaKey(issue)
+	if meta, ok := editMetaCache[cacheKey]; ok {
+		return meta, nil
+	}
+	req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/editmeta", issue.Key), nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if resp, err := jiraClient.Do(req, &result); err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	meta := &jira.MetaIssueType{Name: issue.Fields.Type.Name, Fields: result.Fields}
+	editMetaCache[cacheKey] = meta
+	return meta, nil
+}
+
+func editMetaKey(issue jira.Issue) string {
+	return issue.Fields.Project.Key + "/" + issue.Fields.Type.Name
+}
+
+// getEditPayload resolves the values against the issue's edit screen and adds the label changes.
+// The values are resolved once per edit screen, so users are only looked up once.
+func getEditPayload(jiraClient *jira.Client, issue jira.Issue, values map[string]interface{}) (map[string]interface{}, error) {
+	fields, ok := editFieldsCache[editMetaKey(issue)]
+	if !ok {
+		meta, err := getEditMeta(jiraClient, issue)
+		if err != nil {
+			return nil, err
+		}
+		fields, err = resolveCustomFields(jiraClient, meta, values)
+		if err != nil {
+			return nil, err
+		}
+		editFieldsCache[editMetaKey(issue)] = fields
+	}
+	payload := map[string]interface{}{"fields": fields}
+
+	var labels []interface{}
+	for _, label := range EditAddLabels {
+		labels = append(labels, map[string]string{"add": label})
+	}
+	for _, label := range EditRemoveLabels {
+		labels = append(labels, map[string]string{"remove": label})
+	}
+	if len(labels) > 0 {
+		if _, ok := fields["labels"]; ok {
+			return nil, fmt.Errorf("labels can't be both set and added to or removed from")
+		}
+		payload["update"] = map[string]interface{}{"labels": labels}
+	}
+	return payload, nil
+}
+
+func printEditPlan(issues []jira.Issue, values map[string]interface{}) {
+	for _, issue := range issues {
+		fmt.Printf("%s\t%s\t%s\n", issue.Key, issue.Fields.Status.Name, issue.Fields.Summary)
+	}
+	fmt.Println("\nChanges:")
+	for _, field := range append(append([]string{}, EditSet...), EditSetField...) {
+		parts := strings.SplitN(field, "=", 2)
+		fmt.Printf("  %s = %v\n", strings.TrimSpace(parts[0]), values[strings.TrimSpace(parts[0])])
+	}
+	for _, label := range EditAddLabels {
+		color.Green("  + label %s", label)
+	}
+	for _, label := range EditRemoveLabels {
+		color.Red("  - label %s", label)
+	}
+	fmt.Println()
+}
+
+// getPreviousValues reads the current values of the fields that are about to change
+func getPreviousValues(jiraClient *jira.Client, issues []jira.Issue, fieldIDs map[string]bool) []EditUndoEntry {
+	var fields []string
+	for id := range fieldIDs {
+		fields = append(fields, id)
+	}
+
+	sprintFieldID := getCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+	var entries []EditUndoEntry
+	for start := 0; start < len(issues); start += 100 {
+		end := start + 100
+		if end > len(issues) {
+			end = len(issues)
+		}
+		var keys []string
+		for _, issue := range issues[start:end] {
+			keys = append(keys, issue.Key)
+		}
+		body := map[string]interface{}{
+			"jql":        jql.New().In("key", keys...).String(),
+			"fields":     fields,
+			"maxResults": len(keys),
+		}
+		req, err := jiraClient.NewRequest("POST", "rest/api/2/search", body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var result struct {
+			Issues []struct {
+				Key    string                 `json:"key"`
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"issues"`
+		}
+		if resp, err := jiraClient.Do(req, &result); err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+		for _, issue := range result.Issues {
+			entry := EditUndoEntry{Key: issue.Key, Fields: map[string]interface{}{}}
+			for _, id := range fields {
+				if id == sprintFieldID {
+					entry.Fields[id] = restorableSprint(issue.Fields[id])
+					continue
+				}
+				entry.Fields[id] = restorableValue(issue.Fields[id])
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// restorableValue trims a field value as the API returns it down to what is needed to
+// set it again: the account id or username of users, and the id of other objects
+func restorableValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		restored := make([]interface{}, len(v))
+		for i, item := range v {
+			restored[i] = restorableValue(item)
+		}
+		return restored
+	case map[string]interface{}:
+		for _, key := range []string{"accountId", "name", "id"} {
+			if id, ok := v[key]; ok {
+				if key == "name" && v["key"] == nil {
+					// only Server users are identified by name
+					continue
+				}
+				return map[string]interface{}{key: id}
+			}
+		}
+	}
+	return value
+}
+
+// restorableSprint returns the id of the open sprint in a sprint field, which is
+// how the field is set, or nil when the issue was in no open sprint
+func restorableSprint(value interface{}) interface{} {
+	values, _ := value.([]interface{})
+	sprints := parseSprints(values)
+	for i := len(sprints) - 1; i >= 0; i-- {
+		if sprints[i].State != "closed" {
+			return sprints[i].ID
+		}
+	}
+	return nil
+}
+
+func writeUndoLog(path string, undo EditUndoLog) error {
+	data, err := json.MarshalIndent(undo, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func readUndoLog(path string) (EditUndoLog, error) {
+	var undo EditUndoLog
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return undo, err
+	}
+	if err := json.Unmarshal(data, &undo); err != nil {
+		return undo, fmt.Errorf("%s is not an undo log: %s", path, err)
+	}
+	return undo, nil
+}
+
+// undoEdit puts back the values saved in an undo log
+func undoEdit(jiraClient *jira.Client, path string) {
+	undo, err := readUndoLog(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(undo.Issues) == 0 {
+		fmt.Println("The undo log has no issues")
+		return
+	}
+	fmt.Printf("Restoring %d issues edited %s with %s\n", len(undo.Issues), undo.Created.Local().Format("2006-01-02 15:04"), undo.JQL)
+	if !EditYes && !confirm("Restore the previous values?") {
+		return
+	}
+
+	results := make([]EditResult, len(undo.Issues))
+	forEachConcurrently(len(undo.Issues), EditConcurrency, EditRate, func(i int) {
+		entry := undo.Issues[i]
+		payload := map[string]interface{}{"fields": entry.Fields}
+		results[i] = EditResult{Key: entry.Key, Err: updateIssue(jiraClient, entry.Key, payload)}
+	})
+	printEditResults(results, "restored")
+}
+
+func updateIssue(jiraClient *jira.Client, key string, payload map[string]interface{}) error {
+	if resp, err := jiraClient.Issue.UpdateIssue(key, payload); err != nil {
+		return jira.NewJiraError(resp, err)
+	}
+	return nil
+}
+
+func printEditResults(results []EditResult, verb string) {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			color.Red("%s failed: %s", result.Key, result.Err)
+			failed++
+		} else {
+			color.Green("%s %s", result.Key, verb)
+		}
+	}
+	fmt.Printf("\n%d %s, %d failed\n", len(results)-failed, verb, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// forEachConcurrently calls work for 0 to count-1 from a pool of workers, starting at
+// most perSecond calls a second when it is above zero
+func forEachConcurrently(count int, concurrency int, perSecond float64, work func(int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var ticks <-chan time.Time
+	if perSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / perSecond))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				work(index)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		if ticks != nil {
+			<-ticks
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+}
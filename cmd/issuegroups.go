@@ -0,0 +1,260 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	"github.com/patrickjmcd/jira-tools/jql"
+)
+
+// IssueOutput is the JSON representation of an issue
+type IssueOutput struct {
+	Key      string `json:"key"`
+	Summary  string `json:"summary"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Priority string `json:"priority,omitempty"`
+	URL      string `json:"url"`
+}
+
+// IssueGroup holds the issues that share the same value of the grouped field
+type IssueGroup struct {
+	Name   string
+	Count  int
+	Issues []jira.Issue
+	// None marks the group of issues without a value, such as "No sprint"
+	None bool
+}
+
+type issueListOutput struct {
+	Total  int           `json:"total"`
+	Issues []IssueOutput `json:"issues"`
+}
+
+type groupedIssueListOutput struct {
+	GroupBy string             `json:"groupBy"`
+	Total   int                `json:"total"`
+	Groups  []issueGroupOutput `json:"groups"`
+}
+
+type issueGroupOutput struct {
+	Name   string        `json:"name"`
+	Count  int           `json:"count"`
+	Issues []IssueOutput `json:"issues"`
+}
+
+var groupByFields = []string{"project", "status", "priority", "sprint", "epic"}
+
+func validGroupBy(groupBy string) bool {
+	for _, field := range groupByFields {
+		if field == groupBy {
+			return true
+		}
+	}
+	return false
+}
+
+func newIssueOutput(i *jira.Issue, baseURL string) IssueOutput {
+	output := IssueOutput{
+		Key:     i.Key,
+		Summary: i.Fields.Summary,
+		Type:    i.Fields.Type.Name,
+		URL:     browseURL(baseURL, i.Key),
+	}
+	if i.Fields.Status != nil {
+		output.Status = i.Fields.Status.Name
+	}
+	if i.Fields.Priority != nil {
+		output.Priority = i.Fields.Priority.Name
+	}
+	return output
+}
+
+// groupIssues splits issues into groups by project, status, priority, sprint or epic,
+// keeping the original order of issues within each group
+func groupIssues(jiraClient *jira.Client, issues []jira.Issue, groupBy string) ([]IssueGroup, error) {
+	// groupName returns the name of the issue's group and whether the issue has no value
+	var groupName func(i *jira.Issue) (string, bool)
+	sortKey := func(name string) string { return strings.ToLower(name) }
+
+	switch groupBy {
+	case "project":
+		groupName = func(i *jira.Issue) (string, bool) { return i.Fields.Project.Name, false }
+	case "status":
+		groupName = func(i *jira.Issue) (string, bool) { return i.Fields.Status.Name, false }
+	case "priority":
+		priorityIDs := map[string]int{}
+		groupName = func(i *jira.Issue) (string, bool) {
+			if i.Fields.Priority == nil {
+				return "No priority", true
+			}
+			id, _ := strconv.Atoi(i.Fields.Priority.ID)
+			priorityIDs[i.Fields.Priority.Name] = id
+			return i.Fields.Priority.Name, false
+		}
+		// priorities are ordered by id, highest first
+		sortKey = func(name string) string { return fmt.Sprintf("%010d", priorityIDs[name]) }
+	case "sprint":
+		sprintFieldID, err := findCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+		if err != nil {
+			return nil, err
+		}
+		groupName = func(i *jira.Issue) (string, bool) {
+			sprints := getSprintNames(i, sprintFieldID)
+			if len(sprints) == 0 {
+				return "No sprint", true
+			}
+			return sprints[len(sprints)-1], false
+		}
+	case "epic":
+		epicLinkFieldID, err := findCustomFieldID(jiraClient, epicLinkFieldType, "Epic Link")
+		if err != nil {
+			return nil, err
+		}
+		epicNames, err := getEpicNames(jiraClient, issues, epicLinkFieldID)
+		if err != nil {
+			return nil, err
+		}
+		groupName = func(i *jira.Issue) (string, bool) {
+			key := getEpicKey(i, epicLinkFieldID)
+			if key == "" {
+				return "No epic", true
+			}
+			return epicNames[key], false
+		}
+	default:
+		return nil, fmt.Errorf("can't group by %q, use one of %s", groupBy, strings.Join(groupByFields, ", "))
+	}
+
+	type groupKey struct {
+		name string
+		none bool
+	}
+	groupIndex := map[groupKey]int{}
+	var groups []IssueGroup
+	for _, issue := range issues {
+		name, none := groupName(&issue)
+		index, ok := groupIndex[groupKey{name, none}]
+		if !ok {
+			index = len(groups)
+			groupIndex[groupKey{name, none}] = index
+			groups = append(groups, IssueGroup{Name: name, None: none})
+		}
+		groups[index].Issues = append(groups[index].Issues, issue)
+		groups[index].Count++
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		// groups without a value always go last
+		if groups[i].None != groups[j].None {
+			return groups[j].None
+		}
+		return sortKey(groups[i].Name) < sortKey(groups[j].Name)
+	})
+	return groups, nil
+}
+
+// getEpicNames looks up the summaries of the epics the issues belong to so
+// groups can be labelled "KEY Summary"
+func getEpicNames(jiraClient *jira.Client, issues []jira.Issue, epicLinkFieldID string) (map[string]string, error) {
+	names := map[string]string{}
+	var keys []string
+	for _, issue := range issues {
+		key := getEpicKey(&issue, epicLinkFieldID)
+		if _, seen := names[key]; key != "" && !seen {
+			names[key] = key
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return names, nil
+	}
+
+	query := jql.New().In("key", keys...).String()
+	epics, err := searchIssues(jiraClient, query, &jira.SearchOptions{Fields: []string{"summary"}})
+	if err != nil {
+		return nil, err
+	}
+	for _, epic := range epics {
+		names[epic.Key] = epic.Key + " " + epic.Fields.Summary
+	}
+	return names, nil
+}
+
+func printIssueGroups(groups []IssueGroup, total int, baseURL string, format string) {
+	switch format {
+	case "markdown":
+		for _, group := range groups {
+			fmt.Printf("## %s (%d)\n\n", group.Name, group.Count)
+			for _, issue := range group.Issues {
+				fmt.Print(getMarkdownIssue(&issue, baseURL))
+			}
+			fmt.Println()
+		}
+		fmt.Printf("**Total:** %d issues in %d groups\n", total, len(groups))
+	default:
+		for _, group := range groups {
+			color.Cyan("%s (%d)", group.Name, group.Count)
+			for _, issue := range group.Issues {
+				printIssue(&issue, baseURL)
+			}
+			fmt.Println()
+		}
+		counts := make([]string, len(groups))
+		for i, group := range groups {
+			counts[i] = fmt.Sprintf("%s: %d", group.Name, group.Count)
+		}
+		color.Cyan("%d issues in %d groups (%s)", total, len(groups), strings.Join(counts, ", "))
+	}
+}
+
+func printIssuesJSON(issues []jira.Issue, groups []IssueGroup, groupBy string, baseURL string) {
+	var output interface{}
+	if groupBy == "" {
+		list := issueListOutput{Total: len(issues), Issues: []IssueOutput{}}
+		for _, issue := range issues {
+			list.Issues = append(list.Issues, newIssueOutput(&issue, baseURL))
+		}
+		output = list
+	} else {
+		grouped := groupedIssueListOutput{GroupBy: groupBy, Total: len(issues), Groups: []issueGroupOutput{}}
+		for _, group := range groups {
+			groupOutput := issueGroupOutput{Name: group.Name, Count: group.Count}
+			for _, issue := range group.Issues {
+				groupOutput.Issues = append(groupOutput.Issues, newIssueOutput(&issue, baseURL))
+			}
+			grouped.Groups = append(grouped.Groups, groupOutput)
+		}
+		output = grouped
+	}
+
+	encoded, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(encoded))
+}
+
+func getMarkdownIssue(i *jira.Issue, baseURL string) string {
+	return fmt.Sprintf("- [%s](%s) %s (%s) -- %s\n", i.Key, browseURL(baseURL, i.Key), i.Fields.Summary, i.Fields.Type.Name, i.Fields.Status.Name)
+}
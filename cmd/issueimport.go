@@ -0,0 +1,525 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ImportRow is an issue to import. Parent, Epic and Blocks refer either to the
+// ID of another row or to the key of an existing issue.
+type ImportRow struct {
+	ID            string `yaml:"id"`
+	IssueTemplate `yaml:",inline"`
+	Epic          string   `yaml:"epic"`
+	Blocks        []string `yaml:"blocks"`
+}
+
+// bulkCreateResponse is the reply of the bulk create endpoint
+type bulkCreateResponse struct {
+	Issues []struct {
+		Key string `json:"key"`
+	} `json:"issues"`
+	Errors []struct {
+		FailedElementNumber int `json:"failedElementNumber"`
+		ElementErrors       struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		} `json:"elementErrors"`
+	} `json:"errors"`
+}
+
+// bulkCreateLimit is the most issues the bulk create endpoint accepts at once
+const bulkCreateLimit = 50
+
+// ImportProject is the project for rows that don't name one
+var ImportProject string
+
+// ImportDryRun validates the file and shows what would be created without creating anything
+var ImportDryRun bool
+
+// issueImportCmd represents the issue import command
+var issueImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Creates issues in bulk from a CSV or YAML file",
+	Long: `Creates the issues listed in a CSV or YAML file. Every row is checked against
+the project's create metadata before anything is created.
+
+Rows can refer to each other by their id: parent and epic take the id of
+another row or the key of an existing issue, and blocks takes a list of them.
+Parents and epics are created first.
+
+YAML files hold a list of issues:
+
+  - id: login
+    type: Story
+    summary: Log in with SSO
+    fields:
+      Story Points: 5
+  - id: login-ui
+    type: Sub-task
+    parent: login
+    summary: Login button
+    blocks: [release-notes]
+
+CSV files have a header row with the columns id, project, type, summary,
+description, assignee, labels, components, parent, epic and blocks. Lists are
+comma separated, and any other column sets the field with that name.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rows := readImportFile(args[0])
+		for i := range rows {
+			if rows[i].Project == "" {
+				rows[i].Project = ImportProject
+			}
+			if rows[i].ID == "" {
+				rows[i].ID = fmt.Sprintf("row%d", i+1)
+			}
+		}
+
+		jiraClient, url := jirasetup.GetJiraClient()
+		batches, err := orderImportRows(rows)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if errs := validateImportRows(jiraClient, rows); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			log.Fatalf("%d problems found, nothing was created", len(errs))
+		}
+
+		if ImportDryRun {
+			printImportPlan(batches)
+			return
+		}
+		keys := importRows(jiraClient, batches)
+		linkImportedRows(jiraClient, rows, keys)
+		for _, row := range rows {
+			fmt.Printf("%s\t%s\t%s\n", row.ID, keys[row.ID], browseURL(url, keys[row.ID]))
+		}
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueImportCmd)
+
+	issueImportCmd.Flags().StringVarP(&ImportProject, "project", "p", "", "project key for rows that don't name one")
+	issueImportCmd.Flags().BoolVar(&ImportDryRun, "dry-run", false, "validate the file and show what would be created")
+}
+
+func readImportFile(path string) []ImportRow {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var rows []ImportRow
+		if err := yaml.Unmarshal(data, &rows); err != nil {
+			log.Fatalf("Couldn't read %s: %s", path, err)
+		}
+		for _, row := range rows {
+			for field, value := range row.Fields {
+				row.Fields[field] = normalizeYAML(value)
+			}
+		}
+		return rows
+	case ".csv":
+		rows, err := parseImportCSV(string(data))
+		if err != nil {
+			log.Fatalf("Couldn't read %s: %s", path, err)
+		}
+		return rows
+	}
+	log.Fatalf("%s must be a .csv, .yaml or .yml file", path)
+	return nil
+}
+
+func parseImportCSV(data string) ([]ImportRow, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+
+	var rows []ImportRow
+	header := records[0]
+	for _, record := range records[1:] {
+		var row ImportRow
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(column)) {
+			case "id":
+				row.ID = value
+			case "project":
+				row.Project = value
+			case "type", "issue type":
+				row.Type = value
+			case "summary":
+				row.Summary = value
+			case "description":
+				row.Description = value
+			case "assignee":
+				row.Assignee = value
+			case "labels":
+				row.Labels = jql.Split(value)
+			case "components":
+				row.Components = jql.Split(value)
+			case "parent":
+				row.Parent = value
+			case "epic":
+				row.Epic = value
+			case "blocks":
+				row.Blocks = jql.Split(value)
+			default:
+				if row.Fields == nil {
+					row.Fields = map[string]interface{}{}
+				}
+				row.Fields[strings.TrimSpace(column)] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// orderImportRows splits the rows into batches so every row's parent and epic
+// are created in an earlier batch
+func orderImportRows(rows []ImportRow) ([][]*ImportRow, error) {
+	byID := map[string]*ImportRow{}
+	for i := range rows {
+		if byID[rows[i].ID] != nil {
+			return nil, fmt.Errorf("more than one row has the id %q", rows[i].ID)
+		}
+		byID[rows[i].ID] = &rows[i]
+	}
+	for _, row := range rows {
+		for _, blocked := range row.Blocks {
+			if byID[blocked] == nil && !isIssueKey(blocked) {
+				return nil, fmt.Errorf("%s blocks %q, which is neither a row id nor an issue key", row.ID, blocked)
+			}
+		}
+	}
+
+	depth := map[string]int{}
+	var depthOf func(row *ImportRow, seen map[string]bool) (int, error)
+	depthOf = func(row *ImportRow, seen map[string]bool) (int, error) {
+		if d, ok := depth[row.ID]; ok {
+			return d, nil
+		}
+		if seen[row.ID] {
+			return 0, fmt.Errorf("%s is part of a parent or epic cycle", row.ID)
+		}
+		seen[row.ID] = true
+		d := 0
+		for _, ref := range []string{row.Parent, row.Epic} {
+			if ref == "" {
+				continue
+			}
+			parent := byID[ref]
+			if parent == nil {
+				if !isIssueKey(ref) {
+					return 0, fmt.Errorf("%s refers to %q, which is neither a row id nor an issue key", row.ID, ref)
+				}
+				continue
+			}
+			parentDepth, err := depthOf(parent, seen)
+			if err != nil {
+				return 0, err
+			}
+			if parentDepth+1 > d {
+				d = parentDepth + 1
+			}
+		}
+		depth[row.ID] = d
+		return d, nil
+	}
+
+	var batches [][]*ImportRow
+	for i := range rows {
+		d, err := depthOf(&rows[i], map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		for len(batches) <= d {
+			batches = append(batches, nil)
+		}
+		batches[d] = append(batches[d], &rows[i])
+	}
+	return batches, nil
+}
+
+func isIssueKey(ref string) bool {
+	return issueKeyPattern.MatchString(ref)
+}
+
+// importSpec returns the row as a template with its references resolved to keys.
+// References to rows that haven't been created yet use placeholder keys.
+func importSpec(jiraClient *jira.Client, row *ImportRow, keys map[string]string) (IssueTemplate, error) {
+	resolve := func(ref string) string {
+		if key, ok := keys[ref]; ok {
+			return key
+		}
+		if isIssueKey(ref) {
+			return ref
+		}
+		return "NEW-1"
+	}
+
+	spec := row.IssueTemplate
+	if spec.Parent != "" {
+		spec.Parent = resolve(spec.Parent)
+	}
+	if row.Epic == "" {
+		return spec, nil
+	}
+
+	// classic projects link stories to epics with the Epic Link field, next-gen
+	// projects make the epic the parent
+	epicLinkFieldID := getCustomFieldID(jiraClient, epicLinkFieldType, "Epic Link")
+	if epicLinkFieldID != "" && spec.Project != "" {
+		meta, err := getCreateMeta(jiraClient, spec.Project, importType(spec))
+		if err != nil {
+			return spec, err
+		}
+		if id, _ := findMetaField(meta, epicLinkFieldID); id != "" {
+			fields := map[string]interface{}{epicLinkFieldID: resolve(row.Epic)}
+			for name, value := range spec.Fields {
+				fields[name] = value
+			}
+			spec.Fields = fields
+			return spec, nil
+		}
+	}
+	if spec.Parent == "" {
+		spec.Parent = resolve(row.Epic)
+	}
+	return spec, nil
+}
+
+func importType(spec IssueTemplate) string {
+	if spec.Type == "" {
+		return "Task"
+	}
+	return spec.Type
+}
+
+// validateImportRows builds every issue and checks its required fields without creating anything
+func validateImportRows(jiraClient *jira.Client, rows []ImportRow) []error {
+	var errs []error
+	for i := range rows {
+		row := &rows[i]
+		issue, err := buildImportIssue(jiraClient, row, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", row.ID, err))
+			continue
+		}
+		meta, err := getCreateMeta(jiraClient, row.Project, importType(row.IssueTemplate))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", row.ID, err))
+			continue
+		}
+		for _, missing := range getMissingFields(meta, issue) {
+			errs = append(errs, fmt.Errorf("%s: %s is required", row.ID, missing))
+		}
+	}
+	return errs
+}
+
+// getMissingFields returns the names of required fields without a default that the issue doesn't set
+func getMissingFields(meta *jira.MetaIssueType, issue *jira.Issue) []string {
+	data, err := json.Marshal(issue.Fields)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var set map[string]interface{}
+	json.Unmarshal(data, &set)
+
+	var missing []string
+	for id, value := range meta.Fields {
+		field, _ := value.(map[string]interface{})
+		required, _ := field["required"].(bool)
+		hasDefault, _ := field["hasDefaultValue"].(bool)
+		if !required || hasDefault || id == "reporter" {
+			continue
+		}
+		if _, ok := set[id]; !ok {
+			name, _ := field["name"].(string)
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func printImportPlan(batches [][]*ImportRow) {
+	for i, batch := range batches {
+		fmt.Printf("Batch %d\n", i+1)
+		for _, row := range batch {
+			fmt.Printf("  %s\t%s %s: %s", row.ID, row.Project, importType(row.IssueTemplate), row.Summary)
+			if row.Parent != "" {
+				fmt.Printf(" (parent %s)", row.Parent)
+			}
+			if row.Epic != "" {
+				fmt.Printf(" (epic %s)", row.Epic)
+			}
+			if len(row.Blocks) > 0 {
+				fmt.Printf(" (blocks %s)", strings.Join(row.Blocks, ", "))
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Println("Dry run, nothing was created")
+}
+
+// buildImportIssue builds the issue for a row with its references resolved to keys
+func buildImportIssue(jiraClient *jira.Client, row *ImportRow, keys map[string]string) (*jira.Issue, error) {
+	spec, err := importSpec(jiraClient, row, keys)
+	if err != nil {
+		return nil, err
+	}
+	return buildIssue(jiraClient, spec)
+}
+
+// stopImport prints the keys created so far and exits
+func stopImport(keys map[string]string, err error) {
+	for id, key := range keys {
+		fmt.Printf("%s\t%s\n", id, key)
+	}
+	log.Fatalf("Import stopped after creating %d issues: %s", len(keys), err)
+}
+
+// importRows creates the batches in order and returns the key created for each row id
+func importRows(jiraClient *jira.Client, batches [][]*ImportRow) map[string]string {
+	keys := map[string]string{}
+	for _, batch := range batches {
+		for start := 0; start < len(batch); start += bulkCreateLimit {
+			end := start + bulkCreateLimit
+			if end > len(batch) {
+				end = len(batch)
+			}
+			var issues []*jira.Issue
+			for _, row := range batch[start:end] {
+				issue, err := buildImportIssue(jiraClient, row, keys)
+				if err != nil {
+					stopImport(keys, fmt.Errorf("%s: %s", row.ID, err))
+				}
+				issues = append(issues, issue)
+			}
+
+			created, err := bulkCreateIssues(jiraClient, issues)
+			for i, key := range created {
+				if key != "" {
+					keys[batch[start+i].ID] = key
+				}
+			}
+			if err != nil {
+				stopImport(keys, err)
+			}
+		}
+	}
+	return keys
+}
+
+// bulkCreateIssues creates up to 50 issues in one request and returns the key of
+// each issue by its position, empty for the issues that failed
+func bulkCreateIssues(jiraClient *jira.Client, issues []*jira.Issue) ([]string, error) {
+	updates := make([]map[string]interface{}, len(issues))
+	for i, issue := range issues {
+		updates[i] = map[string]interface{}{"fields": issue.Fields}
+	}
+	req, err := jiraClient.NewRequest("POST", "rest/api/2/issue/bulk", map[string]interface{}{"issueUpdates": updates})
+	if err != nil {
+		return nil, err
+	}
+
+	var response bulkCreateResponse
+	resp, err := jiraClient.Do(req, &response)
+	if err != nil {
+		// when every issue fails the errors come back with a 400
+		if resp == nil || resp.StatusCode != 400 {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		defer resp.Body.Close()
+		if decodeErr := json.NewDecoder(resp.Body).Decode(&response); decodeErr != nil || len(response.Errors) == 0 {
+			return nil, err
+		}
+	}
+
+	// the created issues are listed in order, leaving out the elements that failed
+	failed := map[int]bool{}
+	for _, failure := range response.Errors {
+		failed[failure.FailedElementNumber] = true
+	}
+	keys := make([]string, len(issues))
+	next := 0
+	for i := range keys {
+		if failed[i] || next >= len(response.Issues) {
+			continue
+		}
+		keys[i] = response.Issues[next].Key
+		next++
+	}
+	if len(response.Errors) > 0 {
+		var messages []string
+		for _, failure := range response.Errors {
+			details := failure.ElementErrors.ErrorMessages
+			for field, message := range failure.ElementErrors.Errors {
+				details = append(details, field+": "+message)
+			}
+			messages = append(messages, fmt.Sprintf("issue %d: %s", failure.FailedElementNumber+1, strings.Join(details, "; ")))
+		}
+		return keys, fmt.Errorf("%s", strings.Join(messages, ", "))
+	}
+	return keys, nil
+}
+
+// linkImportedRows adds the blocks links once every row has been created
+func linkImportedRows(jiraClient *jira.Client, rows []ImportRow, keys map[string]string) {
+	for _, row := range rows {
+		for _, blocked := range row.Blocks {
+			blockedKey := blocked
+			if key, ok := keys[blocked]; ok {
+				blockedKey = key
+			}
+			// Jira shows the inward issue as the one that blocks the outward issue
+			_, err := jiraClient.Issue.AddLink(&jira.IssueLink{
+				Type:         jira.IssueLinkType{Name: "Blocks"},
+				InwardIssue:  &jira.Issue{Key: keys[row.ID]},
+				OutwardIssue: &jira.Issue{Key: blockedKey},
+			})
+			if err != nil {
+				fmt.Printf("Couldn't link %s to %s: %s\n", keys[row.ID], blockedKey, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/spf13/cobra"
+)
+
+// issueTransition is a transition with the full metadata of its screen fields
+type issueTransition struct {
+	ID     string                            `json:"id"`
+	Name   string                            `json:"name"`
+	To     jira.Status                       `json:"to"`
+	Fields map[string]map[string]interface{} `json:"fields"`
+}
+
+// MoveResult is the outcome of moving one issue
+type MoveResult struct {
+	Key  string
+	From string
+	To   string
+	Err  error
+}
+
+// MoveJQL selects the issues to move in bulk
+var MoveJQL string
+
+// MoveTo is the status or transition to move the issues to in bulk
+var MoveTo string
+
+// MoveResolution is the resolution to set when the transition asks for one
+var MoveResolution string
+
+// MoveComment is a comment to add with the transition
+var MoveComment string
+
+// MoveFields holds Name=value pairs for other fields on the transition screen
+var MoveFields []string
+
+// MoveYes skips the confirmation prompt of bulk moves
+var MoveYes bool
+
+// MoveConcurrency is how many issues to move at once
+var MoveConcurrency int
+
+// issueMoveCmd represents the issue move command
+var issueMoveCmd = &cobra.Command{
+	Use:   "move [KEY STATUS]",
+	Short: "Moves issues through the workflow",
+	Long: `Moves an issue to a status, or through a transition, by name:
+
+  jira-tools issue move ABC-123 "In Progress"
+
+or moves every issue matching a query after asking for confirmation:
+
+  jira-tools issue move --jql "sprint in closedSprints() AND status = Review" --to Done
+
+Fields on the transition screen can be set with --resolution, --comment and
+--field. A required resolution defaults to Done.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+		MoveComment = readTextArg(MoveComment)
+
+		if MoveJQL == "" {
+			if len(args) != 2 {
+				log.Fatal("Use issue move KEY STATUS, or --jql with --to to move issues in bulk")
+			}
+			result := moveIssue(jiraClient, strings.ToUpper(args[0]), args[1])
+			printMoveResult(result)
+			if result.Err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+
+		if MoveTo == "" {
+			log.Fatal("--to is required with --jql")
+		}
+		issues := searchAllIssues(jiraClient, MoveJQL, &jira.SearchOptions{Fields: []string{"summary", "status"}})
+		if len(issues) == 0 {
+			fmt.Println("No issues match the query")
+			return
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s\t%s\t%s\n", issue.Key, issue.Fields.Status.Name, issue.Fields.Summary)
+		}
+		if !MoveYes && !confirm(fmt.Sprintf("Move these %d issues to %s?", len(issues), MoveTo)) {
+			return
+		}
+
+		var keys []string
+		for _, issue := range issues {
+			keys = append(keys, issue.Key)
+		}
+		results := moveIssues(jiraClient, keys, MoveTo, MoveConcurrency)
+		failed := 0
+		for _, result := range results {
+			printMoveResult(result)
+			if result.Err != nil {
+				failed++
+			}
+		}
+		fmt.Printf("\n%d moved, %d failed\n", len(results)-failed, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueMoveCmd)
+
+	issueMoveCmd.Flags().StringVarP(&MoveJQL, "jql", "q", "", "move every issue matching this query")
+	issueMoveCmd.Flags().StringVar(&MoveTo, "to", "", "status or transition to move the issues to")
+	issueMoveCmd.Flags().StringVarP(&MoveResolution, "resolution", "r", "", "resolution to set when the transition has one (default \"Done\" when required)")
+	issueMoveCmd.Flags().StringVarP(&MoveComment, "comment", "m", "", "comment to add with the transition, @file to read it from a file or - for stdin")
+	issueMoveCmd.Flags().StringArrayVarP(&MoveFields, "field", "f", nil, "other transition screen field as \"Name=value\", may be repeated")
+	issueMoveCmd.Flags().BoolVarP(&MoveYes, "yes", "y", false, "don't ask for confirmation")
+	issueMoveCmd.Flags().IntVar(&MoveConcurrency, "concurrency", 4, "number of issues to move at once")
+}
+
+// getTransitions returns the transitions available to the current user with their screen fields
+func getTransitions(jiraClient *jira.Client, key string) ([]issueTransition, error) {
+	req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/transitions?expand=transitions.fields", key), nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Transitions []issueTransition `json:"transitions"`
+	}
+	if resp, err := jiraClient.Do(req, &result); err != nil {
+		return nil, jira.NewJiraError(resp, err)
+	}
+	return result.Transitions, nil
+}
+
+// findTransition matches the target against transition names first and then against
+// the statuses the transitions lead to
+func findTransition(transitions []issueTransition, target string) (issueTransition, error) {
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.Name, target) {
+			return transition, nil
+		}
+	}
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.To.Name, target) {
+			return transition, nil
+		}
+	}
+	var names []string
+	for _, transition := range transitions {
+		names = append(names, fmt.Sprintf("%s (to %s)", transition.Name, transition.To.Name))
+	}
+	if len(names) == 0 {
+		return issueTransition{}, fmt.Errorf("no transitions are available")
+	}
+	return issueTransition{}, fmt.Errorf("no transition to %q, choose from: %s", target, strings.Join(names, ", "))
+}
+
+// getTransitionPayload fills in the resolution, comment and other fields for the transition
+func getTransitionPayload(jiraClient *jira.Client, transition issueTransition) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if field, ok := transition.Fields["resolution"]; ok {
+		required, _ := field["required"].(bool)
+		if MoveResolution != "" {
+			fields["resolution"] = map[string]string{"name": MoveResolution}
+		} else if required {
+			fields["resolution"] = map[string]string{"name": "Done"}
+		}
+	} else if MoveResolution != "" {
+		return nil, fmt.Errorf("the %s transition doesn't set a resolution", transition.Name)
+	}
+
+	meta := &jira.MetaIssueType{Name: transition.Name, Fields: map[string]interface{}{}}
+	for id, field := range transition.Fields {
+		meta.Fields[id] = field
+	}
+	values := map[string]interface{}{}
+	for _, field := range MoveFields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("--field %q must look like Name=value", field)
+		}
+		values[strings.TrimSpace(parts[0])] = parts[1]
+	}
+	custom, err := resolveCustomFields(jiraClient, meta, values)
+	if err != nil {
+		return nil, err
+	}
+	for id, value := range custom {
+		fields[id] = value
+	}
+
+	for id, field := range transition.Fields {
+		required, _ := field["required"].(bool)
+		hasDefault, _ := field["hasDefaultValue"].(bool)
+		if _, set := fields[id]; required && !hasDefault && !set && id != "comment" {
+			name, _ := field["name"].(string)
+			return nil, fmt.Errorf("the %s transition requires %s, set it with --field", transition.Name, name)
+		}
+	}
+
+	payload := map[string]interface{}{
+		"transition": map[string]string{"id": transition.ID},
+		"fields":     fields,
+	}
+	if MoveComment != "" {
+		payload["update"] = map[string]interface{}{
+			"comment": []interface{}{map[string]interface{}{"add": map[string]string{"body": MoveComment}}},
+		}
+	}
+	return payload, nil
+}
+
+// moveIssue moves a single issue to the target status or through the named transition
+func moveIssue(jiraClient *jira.Client, key string, target string) MoveResult {
+	result := MoveResult{Key: key}
+	issue, _, err := jiraClient.Issue.Get(key, &jira.GetQueryOptions{Fields: "status"})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.From = issue.Fields.Status.Name
+	if strings.EqualFold(result.From, target) {
+		result.To = result.From
+		return result
+	}
+
+	transitions, err := getTransitions(jiraClient, key)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	transition, err := findTransition(transitions, target)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	payload, err := getTransitionPayload(jiraClient, transition)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if _, err := jiraClient.Issue.DoTransitionWithPayload(key, payload); err != nil {
+		result.Err = err
+		return result
+	}
+	result.To = transition.To.Name
+	return result
+}
+
+// moveIssues moves the issues with a pool of workers and returns the results in the order of the keys
+func moveIssues(jiraClient *jira.Client, keys []string, target string, concurrency int) []MoveResult {
+	results := make([]MoveResult, len(keys))
+	forEachConcurrently(len(keys), concurrency, 0, func(index int) {
+		results[index] = moveIssue(jiraClient, keys[index], target)
+	})
+	return results
+}
+
+func printMoveResult(result MoveResult) {
+	switch {
+	case result.Err != nil:
+		color.Red("%s failed: %s", result.Key, result.Err)
+	case result.From == result.To:
+		fmt.Printf("%s is already in %s\n", result.Key, result.To)
+	default:
+		color.Green("%s moved from %s to %s", result.Key, result.From, result.To)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/markup"
+	"github.com/spf13/cobra"
+)
+
+// ViewWeb opens the issue in the browser instead of printing it
+var ViewWeb bool
+
+// ViewComments is how many of the latest comments to show
+var ViewComments int
+
+// ViewHistory is how many of the latest changelog entries to show
+var ViewHistory int
+
+// issueViewCmd represents the issue view command
+var issueViewCmd = &cobra.Command{
+	Use:   "view KEY",
+	Short: "Shows the details of an issue",
+	Long: `Shows an issue's fields, description, links, sub-tasks, latest comments and
+latest changes. Rich text is rendered as plain text. --web opens the issue in
+the browser instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToUpper(args[0])
+		jiraClient, url := jirasetup.GetJiraClient()
+
+		if ViewWeb {
+			if err := openBrowser(browseURL(url, key)); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		issue, resp, err := jiraClient.Issue.Get(key, nil)
+		if err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+		if ViewHistory > 0 {
+			// expanding the changelog only returns its first page on Cloud
+			histories, err := getIssueChangelog(jiraClient, issue.Key)
+			if err != nil {
+				log.Fatal(err)
+			}
+			issue.Changelog = &jira.Changelog{Histories: histories}
+		}
+		sprintFieldID := getCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+		printIssueView(issue, getRichText(jiraClient, issue), sprintFieldID, url)
+	},
+}
+
+func init() {
+	issueCmd.AddCommand(issueViewCmd)
+
+	issueViewCmd.Flags().BoolVarP(&ViewWeb, "web", "w", false, "open the issue in the browser")
+	issueViewCmd.Flags().IntVarP(&ViewComments, "comments", "c", 5, "number of latest comments to show")
+	issueViewCmd.Flags().IntVar(&ViewHistory, "history", 10, "number of latest changes to show")
+}
+
+// issueRichText is the description and comments of an issue rendered as plain text
+type issueRichText struct {
+	Description string
+	Comments    map[string]string
+}
+
+// getRichText renders the description and comments. Cloud's v3 API returns them as
+// Atlassian Document Format, while the v2 API used elsewhere returns wiki markup.
+func getRichText(jiraClient *jira.Client, issue *jira.Issue) issueRichText {
+	text := issueRichText{Comments: map[string]string{}}
+	if !isCloud(jiraClient) {
+		text.Description = markup.WikiToText(issue.Fields.Description)
+		if issue.Fields.Comments != nil {
+			for _, comment := range issue.Fields.Comments.Comments {
+				text.Comments[comment.ID] = markup.WikiToText(comment.Body)
+			}
+		}
+		return text
+	}
+
+	req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/3/issue/%s?fields=description,comment", issue.Key), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var result struct {
+		Fields struct {
+			Description interface{} `json:"description"`
+			Comment     struct {
+				Comments []struct {
+					ID   string      `json:"id"`
+					Body interface{} `json:"body"`
+				} `json:"comments"`
+			} `json:"comment"`
+		} `json:"fields"`
+	}
+	if resp, err := jiraClient.Do(req, &result); err != nil {
+		log.Fatal(jira.NewJiraError(resp, err))
+	}
+	text.Description = markup.ADFToText(result.Fields.Description)
+	for _, comment := range result.Fields.Comment.Comments {
+		text.Comments[comment.ID] = markup.ADFToText(comment.Body)
+	}
+	return text
+}
+
+// openBrowser opens a URL with the desktop's default handler
+func openBrowser(url string) error {
+	var openCmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		openCmd = exec.Command("open", url)
+	case "windows":
+		openCmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		openCmd = exec.Command("xdg-open", url)
+	}
+	return openCmd.Start()
+}
+
+func indentText(text string, indent string) string {
+	return indent + strings.Replace(text, "\n", "\n"+indent, -1)
+}
+
+func printIssueView(issue *jira.Issue, text issueRichText, sprintFieldID string, baseURL string) {
+	fields := issue.Fields
+	color.Cyan("%s %s", issue.Key, fields.Summary)
+	fmt.Println(browseURL(baseURL, issue.Key))
+	fmt.Println()
+
+	assignee := userName(fields.Assignee)
+	if assignee == "" {
+		assignee = "Unassigned"
+	}
+	details := [][2]string{
+		{"Type", fields.Type.Name},
+		{"Status", fields.Status.Name},
+		{"Assignee", assignee},
+		{"Reporter", userName(fields.Reporter)},
+	}
+	if fields.Priority != nil {
+		details = append(details, [2]string{"Priority", fields.Priority.Name})
+	}
+	if fields.Parent != nil {
+		details = append(details, [2]string{"Parent", fields.Parent.Key})
+	}
+	if len(fields.Labels) > 0 {
+		details = append(details, [2]string{"Labels", strings.Join(fields.Labels, ", ")})
+	}
+	var names []string
+	for _, component := range fields.Components {
+		names = append(names, component.Name)
+	}
+	if len(names) > 0 {
+		details = append(details, [2]string{"Components", strings.Join(names, ", ")})
+	}
+	names = nil
+	for _, version := range fields.FixVersions {
+		names = append(names, version.Name)
+	}
+	if len(names) > 0 {
+		details = append(details, [2]string{"Fix versions", strings.Join(names, ", ")})
+	}
+	if sprints := getSprintNames(issue, sprintFieldID); len(sprints) > 0 {
+		details = append(details, [2]string{"Sprint", sprints[len(sprints)-1]})
+	}
+	for _, detail := range details {
+		fmt.Printf("%-13s %s\n", detail[0]+":", detail[1])
+	}
+
+	if text.Description != "" {
+		fmt.Println()
+		color.Cyan("Description")
+		fmt.Println(indentText(text.Description, "  "))
+	}
+
+	printIssueLinks(fields.IssueLinks)
+
+	if len(fields.Subtasks) > 0 {
+		fmt.Println()
+		color.Cyan("Sub-tasks")
+		for _, subtask := range fields.Subtasks {
+			fmt.Printf("  %s [%s] %s\n", subtask.Key, subtask.Fields.Status.Name, subtask.Fields.Summary)
+		}
+	}
+
+	if fields.Comments != nil && len(fields.Comments.Comments) > 0 && ViewComments > 0 {
+		comments := fields.Comments.Comments
+		fmt.Println()
+		color.Cyan("Comments (latest %d of %d)", minInt(ViewComments, len(comments)), len(comments))
+		if len(comments) > ViewComments {
+			comments = comments[len(comments)-ViewComments:]
+		}
+		for _, comment := range comments {
+			created := comment.Created
+			if t, err := parseJiraTime(comment.Created); err == nil {
+				created = t.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("  %s, %s\n", comment.Author.DisplayName, created)
+			fmt.Println(indentText(text.Comments[comment.ID], "    "))
+		}
+	}
+
+	if issue.Changelog != nil && len(issue.Changelog.Histories) > 0 && ViewHistory > 0 {
+		fmt.Println()
+		color.Cyan("History")
+		histories := issue.Changelog.Histories
+		if len(histories) > ViewHistory {
+			histories = histories[len(histories)-ViewHistory:]
+		}
+		for _, history := range histories {
+			created := history.Created
+			if t, err := history.CreatedTime(); err == nil {
+				created = t.Local().Format("2006-01-02 15:04")
+			}
+			for _, item := range history.Items {
+				fmt.Printf("  %s %s: %s %q → %q\n", created, history.Author.DisplayName, item.Field, item.FromString, item.ToString)
+			}
+		}
+	}
+}
+
+// printIssueLinks lists the linked issues grouped by how they are related, e.g. "blocks"
+func printIssueLinks(links []*jira.IssueLink) {
+	if len(links) == 0 {
+		return
+	}
+	var relations []string
+	grouped := map[string][]*jira.Issue{}
+	for _, link := range links {
+		relation, linked := link.Type.Outward, link.OutwardIssue
+		if link.InwardIssue != nil {
+			relation, linked = link.Type.Inward, link.InwardIssue
+		}
+		if linked == nil {
+			continue
+		}
+		if _, ok := grouped[relation]; !ok {
+			relations = append(relations, relation)
+		}
+		grouped[relation] = append(grouped[relation], linked)
+	}
+
+	fmt.Println()
+	color.Cyan("Links")
+	for _, relation := range relations {
+		fmt.Printf("  %s\n", relation)
+		for _, linked := range grouped[relation] {
+			status, summary := "", ""
+			if linked.Fields != nil {
+				summary = linked.Fields.Summary
+				if linked.Fields.Status != nil {
+					status = "[" + linked.Fields.Status.Name + "] "
+				}
+			}
+			fmt.Printf("    %s %s%s\n", linked.Key, status, summary)
+		}
+	}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// StatusChange is a single move of an issue from one status to another
+type StatusChange struct {
+	At     time.Time
+	FromID string
+	From   string
+	ToID   string
+	To     string
+}
+
+// MetricsJQL is a custom query selecting the issues to measure
+var MetricsJQL string
+
+// MetricsProject is the project to measure
+var MetricsProject string
+
+// MetricsSprint is the sprint to measure, or "current" for open sprints
+var MetricsSprint string
+
+// MetricsVersion is the fixVersion to measure
+var MetricsVersion string
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Flow metrics calculated from issue changelogs",
+	Long: `Calculates flow metrics such as lead time, cycle time and cumulative flow
+from the status history of the issues selected by --jql, or by --project
+and optionally --sprint or --version.`,
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+
+	metricsCmd.PersistentFlags().StringVarP(&MetricsJQL, "jql", "q", "", "custom query selecting the issues to measure")
+	metricsCmd.PersistentFlags().StringVarP(&MetricsProject, "project", "p", "", "Jira project to measure")
+	metricsCmd.PersistentFlags().StringVarP(&MetricsSprint, "sprint", "s", "", "sprint name to measure, or \"current\" for open sprints")
+	metricsCmd.PersistentFlags().StringVar(&MetricsVersion, "version", "", "fixVersion to measure")
+}
+
+// makeMetricsQuery builds the query from --jql, or from --project and --sprint
+func makeMetricsQuery() *jql.Query {
+	query := jql.New()
+	if MetricsJQL != "" {
+		query.Raw(MetricsJQL)
+	} else if MetricsProject == "" {
+		log.Fatal("You must include either the --jql or the -p/--project string parameter")
+	}
+	if MetricsProject != "" {
+		query.Eq("project", MetricsProject)
+	}
+
+	switch strings.ToLower(MetricsSprint) {
+	case "":
+	case "current":
+		query.Where("sprint", "in", jql.MustFunc("openSprints"))
+	default:
+		query.Eq("sprint", MetricsSprint)
+	}
+	if MetricsVersion != "" {
+		query.Eq("fixVersion", MetricsVersion)
+	}
+	return query
+}
+
+// getStatusCategories maps status ids to their category key: new, indeterminate or done
+func getStatusCategories(jiraClient *jira.Client) map[string]string {
+	statuses, _, err := jiraClient.Status.GetAllStatuses()
+	if err != nil {
+		log.Fatal(err)
+	}
+	categories := make(map[string]string, len(statuses))
+	for _, status := range statuses {
+		categories[status.ID] = status.StatusCategory.Key
+	}
+	return categories
+}
+
+// getStatusChanges returns the status changes in an issue's changelog, oldest first
+func getStatusChanges(issue *jira.Issue) []StatusChange {
+	var changes []StatusChange
+	if issue.Changelog == nil {
+		return changes
+	}
+	for _, history := range issue.Changelog.Histories {
+		at, err := history.CreatedTime()
+		if err != nil {
+			continue
+		}
+		for _, item := range history.Items {
+			if item.Field != "status" {
+				continue
+			}
+			fromID, _ := item.From.(string)
+			toID, _ := item.To.(string)
+			changes = append(changes, StatusChange{at, fromID, item.FromString, toID, item.ToString})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].At.Before(changes[j].At) })
+	return changes
+}
+
+// getInitialStatus returns the status an issue was created in
+func getInitialStatus(issue *jira.Issue, changes []StatusChange) (string, string) {
+	if len(changes) > 0 {
+		return changes[0].FromID, changes[0].From
+	}
+	return issue.Fields.Status.ID, issue.Fields.Status.Name
+}
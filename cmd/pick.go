@@ -0,0 +1,213 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/sahilm/fuzzy"
+	"github.com/spf13/cobra"
+)
+
+// PickJQL selects the issues to pick from
+var PickJQL string
+
+// PickQuery is the text to start the fuzzy search with
+var PickQuery string
+
+// issuePicker is the bubbletea model of the fuzzy issue finder
+type issuePicker struct {
+	lines   []string
+	input   textinput.Model
+	matches fuzzy.Matches
+	cursor  int
+	chosen  int
+	height  int
+}
+
+var pickMatched = lipgloss.NewStyle().Bold(true).Underline(true)
+
+// pickCmd represents the pick command
+var pickCmd = &cobra.Command{
+	Use:   "pick",
+	Short: "Fuzzy finds an issue and prints its key",
+	Long: `Shows a fuzzy finder over the keys and summaries of the issues matching --jql,
+by default the unfinished issues assigned to you, and prints the chosen key. The
+finder is drawn on standard error so the key can be captured:
+
+  git checkout -b $(jira-tools pick)
+
+Type to filter, use the arrow keys to choose and enter to pick. Escape cancels
+and exits with status 1.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+		query := PickJQL
+		if query == "" {
+			query = jql.New().
+				Where("assignee", "=", jql.MustFunc("currentUser")).
+				Where("statusCategory", "!=", jql.String("Done")).
+				OrderBy("updated", true).
+				String()
+		}
+		issues := searchAllIssues(jiraClient, query, &jira.SearchOptions{Fields: []string{"summary", "status"}})
+		if len(issues) == 0 {
+			log.Fatal("No issues match the query")
+		}
+
+		index, err := pickIssue(issues, PickQuery)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if index < 0 {
+			os.Exit(1)
+		}
+		fmt.Println(issues[index].Key)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pickCmd)
+
+	pickCmd.Flags().StringVarP(&PickJQL, "jql", "q", "", "query selecting the issues to pick from (default your unfinished issues)")
+	pickCmd.Flags().StringVar(&PickQuery, "query", "", "text to start the search with")
+}
+
+// pickIssue lets the user choose one of the issues and returns its index, or -1 when cancelled
+func pickIssue(issues []jira.Issue, query string) (int, error) {
+	picker := issuePicker{chosen: -1, input: textinput.New()}
+	for _, issue := range issues {
+		status := ""
+		if issue.Fields.Status != nil {
+			status = " [" + issue.Fields.Status.Name + "]"
+		}
+		picker.lines = append(picker.lines, issue.Key+" "+issue.Fields.Summary+status)
+	}
+	picker.input.Prompt = "> "
+	picker.input.SetValue(query)
+	picker.input.Focus()
+	picker.match()
+
+	// stdout is usually captured by the shell, so draw on stderr
+	model, err := tea.NewProgram(picker, tea.WithOutput(os.Stderr), tea.WithAltScreen()).Run()
+	if err != nil {
+		return -1, err
+	}
+	return model.(issuePicker).chosen, nil
+}
+
+// match ranks the lines against the search text, keeping every line in order when it is empty
+func (p *issuePicker) match() {
+	if text := strings.TrimSpace(p.input.Value()); text != "" {
+		p.matches = fuzzy.Find(text, p.lines)
+	} else {
+		p.matches = make(fuzzy.Matches, len(p.lines))
+		for i, line := range p.lines {
+			p.matches[i] = fuzzy.Match{Str: line, Index: i}
+		}
+	}
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p issuePicker) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (p issuePicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.height = msg.Height
+		return p, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return p, tea.Quit
+		case "enter":
+			if len(p.matches) > 0 {
+				p.chosen = p.matches[p.cursor].Index
+			}
+			return p, tea.Quit
+		case "up", "ctrl+p", "ctrl+k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+			return p, nil
+		case "down", "ctrl+n", "ctrl+j":
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+			return p, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	p.match()
+	return p, cmd
+}
+
+func (p issuePicker) View() string {
+	rows := p.height - 2
+	if rows < 1 {
+		rows = 10
+	}
+	start := 0
+	if p.cursor >= rows {
+		start = p.cursor - rows + 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString(p.input.View() + "\n")
+	sb.WriteString(browserDim.Render(fmt.Sprintf("  %d/%d", len(p.matches), len(p.lines))) + "\n")
+	for i := start; i < len(p.matches) && i < start+rows; i++ {
+		line := highlightMatch(p.matches[i])
+		if i == p.cursor {
+			sb.WriteString(browserSelected.Render(">") + " " + line + "\n")
+		} else {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// highlightMatch marks the characters the search text matched
+func highlightMatch(match fuzzy.Match) string {
+	matched := map[int]bool{}
+	for _, index := range match.MatchedIndexes {
+		matched[index] = true
+	}
+	var sb strings.Builder
+	for index, r := range match.Str {
+		if matched[index] {
+			sb.WriteString(pickMatched.Render(string(r)))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
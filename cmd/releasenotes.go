@@ -21,23 +21,10 @@ import (
 
 	jira "github.com/andygrunwald/go-jira"
 	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
 	"github.com/spf13/cobra"
 )
 
-// SprintData struct
-type SprintData struct {
-	Name             string
-	CompletedIssues  []IssuePrinted
-	IncompleteIssues []IssuePrinted
-	IssueTypes       []string
-}
-
-// IssuePrinted struct
-type IssuePrinted struct {
-	JiraIssue jira.Issue
-	Printed   string
-}
-
 //ReleaseNotes struct
 type ReleaseNotes struct {
 	AllIssues      []jira.Issue
@@ -122,72 +109,24 @@ func getPrintedIssue(i *jira.Issue, baseURL string) string {
 	if i.Fields.Assignee != nil {
 		assignee = i.Fields.Assignee.DisplayName
 	}
-	markdownIssue := fmt.Sprintf("- [%s](%s/browse/%s)(%s) %s -- %s -- %s\n", i.Key, baseURL, i.Key, i.Fields.Type.Name, i.Fields.Summary, assignee, i.Fields.Status.Name)
+	markdownIssue := fmt.Sprintf("- [%s](%s)(%s) %s -- %s -- %s\n", i.Key, browseURL(baseURL, i.Key), i.Fields.Type.Name, i.Fields.Summary, assignee, i.Fields.Status.Name)
 	return markdownIssue
 }
 
-func generateReleasesString(projectsList string, releaseKey string) string {
-
-	var sb strings.Builder
-	projects := strings.Split(projectsList, ",")
-
-	for _, project := range projects {
-		sb.WriteString("\"")
-		sb.WriteString(project)
-		sb.WriteString(" ")
-		sb.WriteString(releaseKey)
-		sb.WriteString("\", ")
+func getReleaseNames(projectsList string, releaseKey string) []string {
+	var releases []string
+	for _, project := range jql.Split(projectsList) {
+		releases = append(releases, project+" "+releaseKey)
 	}
-	trimmed := strings.TrimSuffix(sb.String(), ", ")
-	return trimmed
+	return releases
 }
 
 func getAllAndFilteredReleaseNotes(jiraClient *jira.Client, allQueryString string, filteredQueryString string) ReleaseNotes {
-	// set total results > max results so it gets the request the first time
-	resultsPerPage := 100
-
-	allSearchOpts := jira.SearchOptions{
-		MaxResults: resultsPerPage,
-		StartAt:    0,
-	}
-	filteredSearchOpts := jira.SearchOptions{
-		MaxResults: resultsPerPage,
-		StartAt:    0,
-	}
 	var releaseNotes ReleaseNotes
-	var allIssues []jira.Issue
-	var filteredIssues []jira.Issue
-
-	for {
-		allIssuesPage, resp, err := jiraClient.Issue.Search(allQueryString, &allSearchOpts)
-		if err != nil {
-			log.Fatal(err)
-		}
-		allIssues = append(allIssues, allIssuesPage...)
-
-		if resp.Total < (resp.StartAt + resp.MaxResults) {
-			break
-		}
-		allSearchOpts.StartAt = allSearchOpts.StartAt + resultsPerPage
-
-	}
-	releaseNotes.AllIssues = allIssues
+	releaseNotes.AllIssues = searchAllIssues(jiraClient, allQueryString, nil)
 
 	if filteredQueryString != "" {
-		for {
-			filteredIssuesPage, resp, err := jiraClient.Issue.Search(filteredQueryString, &filteredSearchOpts)
-			if err != nil {
-				log.Fatal(err)
-			}
-			filteredIssues = append(filteredIssues, filteredIssuesPage...)
-
-			if resp.Total < (resp.StartAt + resp.MaxResults) {
-				break
-			}
-			filteredSearchOpts.StartAt = filteredSearchOpts.StartAt + resultsPerPage
-
-		}
-		releaseNotes.FilteredIssues = filteredIssues
+		releaseNotes.FilteredIssues = searchAllIssues(jiraClient, filteredQueryString, nil)
 	}
 
 	return releaseNotes
@@ -208,18 +147,21 @@ func getFilterReleaseNotes(jiraClient *jira.Client, filterID int) ReleaseNotes {
 func getCustomReleaseNotes(jiraClient *jira.Client, queryString string) ReleaseNotes {
 	filteredIssuesSearchJQL := ""
 	if ReleaseLabel != "" {
-		filteredIssuesSearchJQL = "labels = " + ReleaseLabel + " AND " + queryString
+		filteredIssuesSearchJQL = jql.New().Eq("labels", ReleaseLabel).Raw(queryString).String()
 	}
 
 	return getAllAndFilteredReleaseNotes(jiraClient, queryString, filteredIssuesSearchJQL)
 }
 
-func getIssuesForReleases(jiraClient *jira.Client, releasesString string) ReleaseNotes {
+func getIssuesForReleases(jiraClient *jira.Client, releases []string) ReleaseNotes {
+	releaseQuery := jql.New().
+		In("fixVersion", releases...).
+		In("status", "Done", "In Staging", "In Production")
 
-	allIssuesSearchJQL := "fixVersion in (" + releasesString + ") AND status in (Done, \"In Staging\", \"In Production\") ORDER BY issuetype ASC"
+	allIssuesSearchJQL := releaseQuery.Clone().OrderBy("issuetype", false).String()
 	filteredIssuesSearchJQL := ""
 	if ReleaseLabel != "" {
-		filteredIssuesSearchJQL = "fixVersion in (" + releasesString + ") AND status in (Done, \"In Staging\", \"In Production\") AND labels = " + ReleaseLabel + " ORDER BY issuetype ASC"
+		filteredIssuesSearchJQL = releaseQuery.Clone().Eq("labels", ReleaseLabel).OrderBy("issuetype", false).String()
 	}
 
 	return getAllAndFilteredReleaseNotes(jiraClient, allIssuesSearchJQL, filteredIssuesSearchJQL)
@@ -227,7 +169,7 @@ func getIssuesForReleases(jiraClient *jira.Client, releasesString string) Releas
 
 func generateReleaseNotes(jiraClient *jira.Client) {
 	baseURL := fmt.Sprintf("https://%s", jiraClient.GetBaseURL().Host)
-	releasesString := generateReleasesString(ProjectsList, ReleaseKey)
+	releases := getReleaseNames(ProjectsList, ReleaseKey)
 	var releaseNotes ReleaseNotes
 	var sb strings.Builder
 	if Query != "" {
@@ -235,7 +177,7 @@ func generateReleaseNotes(jiraClient *jira.Client) {
 	} else if FilterID > 0 {
 		releaseNotes = getFilterReleaseNotes(jiraClient, FilterID)
 	} else {
-		releaseNotes = getIssuesForReleases(jiraClient, releasesString)
+		releaseNotes = getIssuesForReleases(jiraClient, releases)
 	}
 
 	if ReleaseLabel != "" {
@@ -59,6 +59,7 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.jira-tools.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&ValidateJQL, "validate-jql", false, "validate queries with the Jira server before running them")
 }
 
 // initConfig reads in config file and ENV variables if set.
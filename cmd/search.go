@@ -0,0 +1,250 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/patrickjmcd/jira-tools/jql"
+)
+
+// ValidateJQL asks the server to parse every query before it is run
+var ValidateJQL bool
+
+// validateQuery asks the server to parse the query when --validate-jql is set
+func validateQuery(jiraClient *jira.Client, query string) error {
+	if !ValidateJQL {
+		return nil
+	}
+	return jql.Validate(jiraClient, query)
+}
+
+// searchAllIssues runs the query and keeps requesting pages until every matching
+// issue has been fetched. A nil searchOpts fetches the default fields.
+func searchAllIssues(jiraClient *jira.Client, queryString string, searchOpts *jira.SearchOptions) []jira.Issue {
+	allIssues, err := searchIssues(jiraClient, queryString, searchOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return allIssues
+}
+
+// searchIssues is searchAllIssues returning an error instead of exiting, for callers
+// such as --watch that keep running when a request fails. The query is validated
+// here when --validate-jql is set.
+//
+// The search results only include the first page of an issue's changelog, comments
+// and worklogs, so issues with more are completed with separate requests.
+func searchIssues(jiraClient *jira.Client, queryString string, searchOpts *jira.SearchOptions) ([]jira.Issue, error) {
+	var allIssues []jira.Issue
+	if err := validateQuery(jiraClient, queryString); err != nil {
+		return nil, err
+	}
+
+	params := searchParams(queryString, searchOpts)
+	params.Set("maxResults", "100")
+	for {
+		params.Set("startAt", strconv.Itoa(len(allIssues)))
+		total, issues, totals, err := searchPage(jiraClient, params)
+		if err != nil {
+			return nil, err
+		}
+		for i := range issues {
+			if err := completeIssue(jiraClient, &issues[i], totals[i]); err != nil {
+				return nil, err
+			}
+		}
+		allIssues = append(allIssues, issues...)
+
+		// the server may return fewer issues than asked for, e.g. when expanding changelogs
+		if len(issues) == 0 || len(allIssues) >= total {
+			break
+		}
+	}
+	return allIssues, nil
+}
+
+// countIssues returns the number of issues matching the query without fetching them
+func countIssues(jiraClient *jira.Client, queryString string) (int, error) {
+	if err := validateQuery(jiraClient, queryString); err != nil {
+		return 0, err
+	}
+	params := searchParams(queryString, &jira.SearchOptions{Fields: []string{"key"}})
+	params.Set("maxResults", "0")
+	total, _, _, err := searchPage(jiraClient, params)
+	return total, err
+}
+
+// searchParams returns the query string parameters for the query and its options
+func searchParams(queryString string, searchOpts *jira.SearchOptions) url.Values {
+	params := url.Values{}
+	params.Set("jql", queryString)
+	if searchOpts != nil {
+		if searchOpts.Expand != "" {
+			params.Set("expand", searchOpts.Expand)
+		}
+		if len(searchOpts.Fields) > 0 {
+			params.Set("fields", strings.Join(searchOpts.Fields, ","))
+		}
+	}
+	return params
+}
+
+// searchPage requests one page of search results, returning the total number of
+// matching issues and the histories and comments each issue has
+func searchPage(jiraClient *jira.Client, params url.Values) (int, []jira.Issue, []issueTotals, error) {
+	req, err := jiraClient.NewRequest("GET", "rest/api/2/search?"+params.Encode(), nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var raw json.RawMessage
+	if resp, err := jiraClient.Do(req, &raw); err != nil {
+		return 0, nil, nil, jira.NewJiraError(resp, err)
+	}
+
+	var page struct {
+		Total  int          `json:"total"`
+		Issues []jira.Issue `json:"issues"`
+	}
+	var totals struct {
+		Issues []issueTotals `json:"issues"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return 0, nil, nil, err
+	}
+	if err := json.Unmarshal(raw, &totals); err != nil {
+		return 0, nil, nil, err
+	}
+	return page.Total, page.Issues, totals.Issues, nil
+}
+
+// issueTotals holds the number of histories and comments on an issue, of which
+// the search results may only include the first page
+type issueTotals struct {
+	Key       string `json:"key"`
+	Changelog *struct {
+		Total int `json:"total"`
+	} `json:"changelog"`
+	Fields struct {
+		Comment *struct {
+			Total int `json:"total"`
+		} `json:"comment"`
+	} `json:"fields"`
+}
+
+// completeIssue replaces the changelog, comments and worklogs of an issue with every
+// history, comment and worklog when the search results only included the first page
+func completeIssue(jiraClient *jira.Client, issue *jira.Issue, totals issueTotals) error {
+	if issue.Changelog != nil && totals.Changelog != nil && totals.Changelog.Total > len(issue.Changelog.Histories) {
+		histories, err := getIssueChangelog(jiraClient, issue.Key)
+		if err != nil {
+			return err
+		}
+		issue.Changelog.Histories = histories
+	}
+	if issue.Fields != nil && issue.Fields.Comments != nil && totals.Fields.Comment != nil &&
+		totals.Fields.Comment.Total > len(issue.Fields.Comments.Comments) {
+		comments, err := getIssueComments(jiraClient, issue.Key)
+		if err != nil {
+			return err
+		}
+		issue.Fields.Comments.Comments = comments
+	}
+	if issue.Fields != nil && issue.Fields.Worklog != nil && issue.Fields.Worklog.Total > len(issue.Fields.Worklog.Worklogs) {
+		worklogs, err := getIssueWorklogs(jiraClient, issue.Key)
+		if err != nil {
+			return err
+		}
+		issue.Fields.Worklog.Worklogs = worklogs
+	}
+	return nil
+}
+
+// getIssueChangelog returns every history of an issue. Cloud pages the changelog
+// while Server returns all of it when the issue is expanded.
+func getIssueChangelog(jiraClient *jira.Client, key string) ([]jira.ChangelogHistory, error) {
+	if !isCloud(jiraClient) {
+		issue, resp, err := jiraClient.Issue.Get(key, &jira.GetQueryOptions{Expand: "changelog", Fields: "key"})
+		if err != nil {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		if issue.Changelog == nil {
+			return nil, nil
+		}
+		return issue.Changelog.Histories, nil
+	}
+
+	var histories []jira.ChangelogHistory
+	for {
+		req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/changelog?startAt=%d", key, len(histories)), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Total  int                     `json:"total"`
+			Values []jira.ChangelogHistory `json:"values"`
+		}
+		if resp, err := jiraClient.Do(req, &page); err != nil {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		histories = append(histories, page.Values...)
+		if len(page.Values) == 0 || len(histories) >= page.Total {
+			return histories, nil
+		}
+	}
+}
+
+// getIssueComments returns every comment on an issue
+func getIssueComments(jiraClient *jira.Client, key string) ([]*jira.Comment, error) {
+	var comments []*jira.Comment
+	for {
+		req, err := jiraClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/comment?startAt=%d", key, len(comments)), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Total    int             `json:"total"`
+			Comments []*jira.Comment `json:"comments"`
+		}
+		if resp, err := jiraClient.Do(req, &page); err != nil {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		comments = append(comments, page.Comments...)
+		if len(page.Comments) == 0 || len(comments) >= page.Total {
+			return comments, nil
+		}
+	}
+}
+
+// getIssueWorklogs returns every worklog on an issue
+func getIssueWorklogs(jiraClient *jira.Client, key string) ([]jira.WorklogRecord, error) {
+	var worklogs []jira.WorklogRecord
+	for {
+		page, resp, err := jiraClient.Issue.GetWorklogs(key, jira.WithQueryOptions(&jira.GetWorklogsQueryOptions{StartAt: int64(len(worklogs))}))
+		if err != nil {
+			return nil, jira.NewJiraError(resp, err)
+		}
+		worklogs = append(worklogs, page.Worklogs...)
+		if len(page.Worklogs) == 0 || len(worklogs) >= page.Total {
+			return worklogs, nil
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"encoding/csv"
 	"fmt"
 	"log"
 	"os"
@@ -23,6 +24,7 @@ import (
 
 	jira "github.com/andygrunwald/go-jira"
 	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
 	"github.com/spf13/cobra"
 )
 
@@ -35,6 +37,42 @@ var DaysOfServicedeskItems int
 //OutputFilePath is the file path to output the data
 var OutputFilePath string
 
+// ServicedeskFrom is the first date to include, overriding --days
+var ServicedeskFrom string
+
+// ServicedeskTo is the last date to include
+var ServicedeskTo string
+
+// ServicedeskDateField is the date the window applies to: created, updated or resolved
+var ServicedeskDateField string
+
+// ServicedeskRequestTypes is a comma-separated list of request types to include
+var ServicedeskRequestTypes string
+
+// ServicedeskOrganizations is a comma-separated list of customer organisations to include
+var ServicedeskOrganizations string
+
+// ServicedeskCustomers is a comma-separated list of customers (reporters) to include
+var ServicedeskCustomers string
+
+// ServicedeskStatusCategories is a comma-separated list of status categories to include
+var ServicedeskStatusCategories string
+
+// ServicedeskIncremental only fetches issues updated since the last export and upserts them
+var ServicedeskIncremental bool
+
+// ServicedeskStateFile stores the last updated timestamp exported for each project
+var ServicedeskStateFile string
+
+// ServicedeskSLA adds SLA columns and a breached/at risk/met summary
+var ServicedeskSLA bool
+
+// ServicedeskBreachedOnly only outputs requests with a breached SLA
+var ServicedeskBreachedOnly bool
+
+// ServicedeskAtRisk is how close to its goal a running SLA must be to count as at risk
+var ServicedeskAtRisk string
+
 // servicedeskCmd represents the servicedesk command
 var servicedeskCmd = &cobra.Command{
 	Use:   "servicedesk",
@@ -59,13 +97,65 @@ are used to create linked issues in other boards`,
 			log.Fatal("Couldn't log on to the Jira server.")
 		}
 
-		serviceDeskIssues := getServicedeskIssuesForProject(jiraClient, Project, DaysOfServicedeskItems)
-		csvString := generateCSVFromIssueSlice(jiraClient, serviceDeskIssues)
-		if OutputFilePath != "" {
-			writeCSVToFile(csvString, OutputFilePath)
+		if ServicedeskIncremental && OutputFilePath == "" {
+			log.Fatal("--incremental needs an output file, use -o or --output")
+		}
+		outputFormat := getExportFormat(OutputFilePath)
+
+		var state exportState
+		if ServicedeskIncremental {
+			state = loadExportState(ServicedeskStateFile)
+		}
+
+		serviceDeskIssues := getServicedeskIssuesForProject(jiraClient, Project, DaysOfServicedeskItems, state.Projects[Project])
+
+		var slasByIssue map[string][]RequestSLA
+		if ServicedeskSLA || ServicedeskBreachedOnly {
+			atRisk, err := parseAge(ServicedeskAtRisk)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			slasByIssue = make(map[string][]RequestSLA, len(serviceDeskIssues))
+			for _, issue := range serviceDeskIssues {
+				slasByIssue[issue.Key] = getRequestSLAs(jiraClient, issue.Key)
+			}
+
+			if ServicedeskBreachedOnly {
+				var breached []jira.Issue
+				for _, issue := range serviceDeskIssues {
+					if isBreached(slasByIssue[issue.Key]) {
+						breached = append(breached, issue)
+					}
+				}
+				serviceDeskIssues = breached
+			}
+
+			// keep the summary out of the CSV when it is written to stdout
+			summaryOut := func(format string, a ...interface{}) { fmt.Fprintf(os.Stderr, format, a...) }
+			if OutputFilePath != "" {
+				summaryOut = func(format string, a ...interface{}) { fmt.Printf(format, a...) }
+			}
+			printSLASummary(slasByIssue, serviceDeskIssues, atRisk, summaryOut)
+		}
+
+		if ServicedeskIncremental || outputFormat != "csv" {
+			header, records := getIssueRecords(jiraClient, serviceDeskIssues, slasByIssue)
+			upsertRecords(OutputFilePath, outputFormat, header, records, "Key")
+			fmt.Printf("Exported %d issues to %s\n", len(records), OutputFilePath)
 		} else {
+			csvString := generateCSVFromIssueSlice(jiraClient, serviceDeskIssues, slasByIssue)
+			if OutputFilePath != "" {
+				writeCSVToFile(csvString, OutputFilePath)
+			} else {
+
+				fmt.Println(csvString)
+			}
+		}
 
-			fmt.Println(csvString)
+		if ServicedeskIncremental {
+			state.update(Project, serviceDeskIssues)
+			saveExportState(ServicedeskStateFile, state)
 		}
 	},
 }
@@ -79,66 +169,176 @@ func init() {
 	// and all subcommands, e.g.:
 	// servicedeskCmd.PersistentFlags().String("foo", "", "A help for foo")
 	servicedeskCmd.PersistentFlags().StringVarP(&Project, "project", "p", "", "Jira project to use")
-	servicedeskCmd.PersistentFlags().StringVarP(&OutputFilePath, "output", "o", "", "CSV File to output")
+	servicedeskCmd.PersistentFlags().StringVarP(&OutputFilePath, "output", "o", "", "File to output, format chosen by extension: .csv, .ndjson or .db/.sqlite")
 	servicedeskCmd.MarkFlagRequired("project")
 	servicedeskCmd.PersistentFlags().IntVarP(&DaysOfServicedeskItems, "days", "d", 7, "Days of history to retreive")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskFrom, "from", "", "first date to include, YYYY-MM-DD (overrides --days)")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskTo, "to", "", "last date to include, YYYY-MM-DD")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskDateField, "by", "created", "date the window applies to: created, updated or resolved")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskRequestTypes, "request-type", "", "comma-separated list of request types to include")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskOrganizations, "organization", "", "comma-separated list of customer organisations to include")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskCustomers, "customer", "", "comma-separated list of customers to include")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskStatusCategories, "status-category", "", "comma-separated list of status categories to include (To Do, In Progress, Done)")
+	servicedeskCmd.PersistentFlags().BoolVar(&ServicedeskIncremental, "incremental", false, "only export issues updated since the last run and upsert them into the output file")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskStateFile, "state-file", "", "file holding the last exported timestamp for each project (default is $HOME/.jira-tools-state.json)")
+	servicedeskCmd.PersistentFlags().BoolVar(&ServicedeskSLA, "sla", false, "include SLA status, elapsed and remaining time for each request")
+	servicedeskCmd.PersistentFlags().BoolVar(&ServicedeskBreachedOnly, "breached-only", false, "only include requests with a breached SLA (implies --sla)")
+	servicedeskCmd.PersistentFlags().StringVar(&ServicedeskAtRisk, "at-risk", "1h", "running SLAs with less than this remaining are at risk")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// servicedeskCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func getServicedeskIssuesForProject(jiraClient *jira.Client, projectName string, daysOfHistory int) []jira.Issue {
-	searchOpts := jira.SearchOptions{
-		MaxResults: 999,
+func getServicedeskIssuesForProject(jiraClient *jira.Client, projectName string, daysOfHistory int, updatedSince time.Time) []jira.Issue {
+	query := jql.New().Eq("project", projectName)
+	addServicedeskFilters(jiraClient, query)
+
+	var searchQuery string
+	if updatedSince.IsZero() {
+		addServicedeskDateRange(query, daysOfHistory)
+		sortField := ServicedeskDateField
+		if sortField == "created" {
+			sortField = "createdDate"
+		}
+		searchQuery = query.OrderBy(sortField, true).String()
+	} else {
+		// JQL reads dates in the profile time zone and only compares to the minute, so
+		// this overlaps the last run slightly and relies on the upsert
+		updatedSince = updatedSince.In(getProfileLocation(jiraClient))
+		query.Where("updated", ">=", jql.String(jqlTime(updatedSince)))
+		searchQuery = query.OrderBy("updated", false).String()
 	}
 
-	dateAndQuery := fmt.Sprintf(" and createdDate > startOfDay(-%dd)", daysOfHistory)
-	if daysOfHistory <= 0 {
-		dateAndQuery = ""
+	return searchAllIssues(jiraClient, searchQuery, nil)
+}
+
+// addServicedeskDateRange limits the query to --from/--to, or to the last
+// daysOfHistory days when no dates are given, on the --by date field
+func addServicedeskDateRange(query *jql.Query, daysOfHistory int) {
+	if ServicedeskDateField != "created" && ServicedeskDateField != "updated" && ServicedeskDateField != "resolved" {
+		log.Fatal("--by must be created, updated or resolved")
 	}
+	addDateRange(query, ServicedeskDateField, daysOfHistory)
+}
 
-	searchQuery := fmt.Sprintf("project=%s%s ORDER BY createdDate DESC", projectName, dateAndQuery)
+// addDateRange limits the query to --from/--to, or to the last daysOfHistory
+// days when no dates are given, on the given date field. It refuses to run
+// without any window rather than search all history.
+func addDateRange(query *jql.Query, field string, daysOfHistory int) {
+	if ServicedeskFrom == "" && ServicedeskTo == "" {
+		if daysOfHistory < 1 {
+			log.Fatal("--days must be at least 1 unless --from or --to is given")
+		}
+		query.Where(field, ">", jql.MustFunc("startOfDay", fmt.Sprintf("-%dd", daysOfHistory)))
+		return
+	}
 
-	servicedeskIssues, _, pErr := jiraClient.Issue.Search(searchQuery, &searchOpts)
+	if ServicedeskFrom != "" {
+		query.Where(field, ">=", jql.String(parseServicedeskDate("--from", ServicedeskFrom, time.Local).Format("2006-01-02")))
+	}
+	if ServicedeskTo != "" {
+		// dates without a time mean midnight, so include the whole of the last day
+		to := parseServicedeskDate("--to", ServicedeskTo, time.Local).AddDate(0, 0, 1)
+		query.Where(field, "<", jql.String(to.Format("2006-01-02")))
+	}
+}
 
-	if pErr != nil {
-		log.Fatal(pErr)
+// parseServicedeskDate parses a --from or --to date as midnight in the given time zone
+func parseServicedeskDate(flag string, value string, location *time.Location) time.Time {
+	date, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		log.Fatalf("%s must be a date like 2019-01-31", flag)
 	}
+	return date
+}
 
-	return servicedeskIssues
+// addServicedeskFilters adds the request type, organisation, customer and status category filters
+func addServicedeskFilters(jiraClient *jira.Client, query *jql.Query) {
+	if requestTypes := jql.Split(ServicedeskRequestTypes); len(requestTypes) > 0 {
+		fieldID := getCustomFieldID(jiraClient, requestTypeFieldType, "Customer Request Type", "Request Type")
+		if fieldID == "" {
+			log.Fatal("Couldn't find the request type field, is this a service desk?")
+		}
+		query.In(customFieldClause(fieldID), requestTypes...)
+	}
+	if organizations := jql.Split(ServicedeskOrganizations); len(organizations) > 0 {
+		fieldID := getCustomFieldID(jiraClient, organizationsFieldType, "Organizations")
+		if fieldID == "" {
+			log.Fatal("Couldn't find the organizations field, is this a service desk?")
+		}
+		query.In(customFieldClause(fieldID), organizations...)
+	}
+	query.In("reporter", jql.Split(ServicedeskCustomers)...)
+	query.In("statusCategory", jql.Split(ServicedeskStatusCategories)...)
 }
 
-func generateCSVFromIssueSlice(jiraClient *jira.Client, issues []jira.Issue) string {
+func generateCSVFromIssueSlice(jiraClient *jira.Client, issues []jira.Issue, slasByIssue map[string][]RequestSLA) string {
 	var csvStringBuilder strings.Builder
+	csvWriter := csv.NewWriter(&csvStringBuilder)
 
-	header := "Type,Key,Summary,Status,Assignee,Reporter,Created,Link\n"
-	csvStringBuilder.WriteString(header)
+	header, records := getIssueRecords(jiraClient, issues, slasByIssue)
+	csvWriter.Write(header)
+	csvWriter.WriteAll(records)
 
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal(err)
+	}
+	return csvStringBuilder.String()
+}
+
+// getIssueRecords returns the export columns and one row per issue
+func getIssueRecords(jiraClient *jira.Client, issues []jira.Issue, slasByIssue map[string][]RequestSLA) ([]string, [][]string) {
+	slaNames := getSLANames(slasByIssue, issues)
+
+	header := []string{"Type", "Key", "Summary", "Status", "Assignee", "Reporter", "Created", "Link"}
+	for _, name := range slaNames {
+		header = append(header, name+" Status", name+" Elapsed", name+" Remaining")
+	}
+
+	var records [][]string
+	atRisk, _ := parseAge(ServicedeskAtRisk)
+	base := jiraClient.GetBaseURL()
+	baseURL := strings.TrimSuffix(base.String(), "/")
 	for _, issue := range issues {
 
-		issueLink := fmt.Sprintf("https://%s/browse/%s", jiraClient.GetBaseURL().Host, issue.Key)
+		issueLink := browseURL(baseURL, issue.Key)
 
 		assignee := "Unassigned"
 		if issue.Fields.Assignee != nil {
 			assignee = issue.Fields.Assignee.DisplayName
 		}
 
+		reporter := ""
+		if issue.Fields.Reporter != nil {
+			reporter = issue.Fields.Reporter.DisplayName
+		}
+
 		created := time.Time(issue.Fields.Created)
 
-		issueString := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s\n",
+		record := []string{
 			issue.Fields.Type.Name,
 			issue.Key,
 			issue.Fields.Summary,
 			issue.Fields.Status.Name,
 			assignee,
-			issue.Fields.Reporter.DisplayName,
+			reporter,
 			created.String(),
-			issueLink)
-		csvStringBuilder.WriteString(issueString)
+			issueLink,
+		}
+		for _, name := range slaNames {
+			state, elapsed, remaining := "", "", ""
+			for _, sla := range slasByIssue[issue.Key] {
+				if sla.Name == name {
+					state, elapsed, remaining = sla.State(atRisk), sla.Elapsed(), sla.Remaining()
+				}
+			}
+			record = append(record, state, elapsed, remaining)
+		}
+		records = append(records, record)
 	}
 
-	return csvStringBuilder.String()
+	return header, records
 }
 
 func writeCSVToFile(csvString string, file string) {
@@ -148,6 +348,6 @@ func writeCSVToFile(csvString string, file string) {
 	}
 	defer createdFile.Close()
 
-	fmt.Fprintf(createdFile, csvString)
+	fmt.Fprint(createdFile, csvString)
 
 }
@@ -0,0 +1,345 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// PeriodCount holds the number of tickets created and resolved in a day or week
+type PeriodCount struct {
+	Period   string `json:"period"`
+	Created  int    `json:"created"`
+	Resolved int    `json:"resolved"`
+}
+
+// ServicedeskStats summarises a service desk project over a window of days
+type ServicedeskStats struct {
+	Project               string        `json:"project"`
+	From                  time.Time     `json:"from"`
+	To                    time.Time     `json:"to"`
+	Created               int           `json:"created"`
+	Resolved              int           `json:"resolved"`
+	BacklogStart          int           `json:"backlogStart"`
+	BacklogEnd            int           `json:"backlogEnd"`
+	BacklogGrowth         int           `json:"backlogGrowth"`
+	MedianResolutionHours float64       `json:"medianResolutionHours"`
+	P90ResolutionHours    float64       `json:"p90ResolutionHours"`
+	Periods               []PeriodCount `json:"periods"`
+	ByRequestType         []countEntry  `json:"byRequestType"`
+	ByPriority            []countEntry  `json:"byPriority"`
+	ByAssignee            []countEntry  `json:"byAssignee"`
+	TopReporters          []countEntry  `json:"topReporters"`
+	TopOrganizations      []countEntry  `json:"topOrganizations"`
+}
+
+// StatsInterval is the period tickets are counted by: day or week
+var StatsInterval string
+
+// StatsFormat is the output format: table, json or markdown
+var StatsFormat string
+
+// StatsTop is the number of reporters and organisations to list
+var StatsTop int
+
+// servicedeskStatsCmd represents the servicedesk stats command
+var servicedeskStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarises servicedesk volume, resolution times and requesters",
+	Long: `Summarises the tickets in a servicedesk project over the --days window, or
+--from/--to when given: tickets created and resolved per day or week, median and 90th percentile time
+to resolution, backlog growth, a breakdown by request type, priority and
+assignee, and the top reporters and organisations.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if Project == "" {
+			log.Fatal("You must include the -p or --project string parameter")
+		}
+		// --days only sets the window when no explicit dates are given
+		if ServicedeskFrom == "" && ServicedeskTo == "" && DaysOfServicedeskItems < 1 {
+			log.Fatal("--days must be at least 1 unless --from or --to is given")
+		}
+		if StatsInterval != "day" && StatsInterval != "week" {
+			log.Fatal("--interval must be day or week")
+		}
+		if cmd.Flags().Changed("by") {
+			log.Fatal("--by doesn't apply to stats, tickets are counted by the date they were created and resolved")
+		}
+
+		jiraClient, _ := jirasetup.GetJiraClient()
+		stats := getServicedeskStats(jiraClient, Project, DaysOfServicedeskItems)
+
+		switch StatsFormat {
+		case "json":
+			encoded, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(encoded))
+		case "markdown":
+			fmt.Print(formatStatsMarkdown(stats))
+		case "table":
+			printStatsTable(stats)
+		default:
+			log.Fatal("--format must be table, json or markdown")
+		}
+	},
+}
+
+func init() {
+	servicedeskCmd.AddCommand(servicedeskStatsCmd)
+
+	servicedeskStatsCmd.Flags().StringVar(&StatsInterval, "interval", "day", "count tickets per day or week")
+	servicedeskStatsCmd.Flags().StringVar(&StatsFormat, "format", "table", "output format: table, json or markdown")
+	servicedeskStatsCmd.Flags().IntVar(&StatsTop, "top", 10, "number of reporters and organisations to list")
+}
+
+func getServicedeskStats(jiraClient *jira.Client, projectName string, daysOfHistory int) ServicedeskStats {
+	// JQL reads dates in the profile time zone, so tickets are counted by the days in it too
+	location := getProfileLocation(jiraClient)
+	from, to := getStatsWindow(daysOfHistory, location)
+
+	requestTypeFieldID := getCustomFieldID(jiraClient, requestTypeFieldType, "Customer Request Type", "Request Type")
+	organizationsFieldID := getCustomFieldID(jiraClient, organizationsFieldType, "Organizations")
+	searchOpts := &jira.SearchOptions{
+		Fields: []string{"created", "resolutiondate", "priority", "assignee", "reporter"},
+	}
+	for _, fieldID := range []string{requestTypeFieldID, organizationsFieldID} {
+		if fieldID != "" {
+			searchOpts.Fields = append(searchOpts.Fields, fieldID)
+		}
+	}
+
+	projectQuery := jql.New().Eq("project", projectName)
+	addServicedeskFilters(jiraClient, projectQuery)
+
+	createdQuery := projectQuery.Clone()
+	addDateRange(createdQuery, "created", daysOfHistory)
+	resolvedQuery := projectQuery.Clone()
+	addDateRange(resolvedQuery, "resolved", daysOfHistory)
+	created := searchAllIssues(jiraClient, createdQuery.String(), searchOpts)
+	resolved := searchAllIssues(jiraClient, resolvedQuery.String(), searchOpts)
+
+	openQuery := projectQuery.Clone().IsEmpty("resolution").String()
+	if ServicedeskTo != "" {
+		// the backlog at the end of the window: created before it and not resolved until after it
+		end := jql.String(to.AddDate(0, 0, 1).Format("2006-01-02"))
+		openQuery = projectQuery.Clone().
+			Where("created", "<", end).
+			Any(jql.New().IsEmpty("resolved"), jql.New().Where("resolved", ">=", end)).
+			String()
+	}
+	backlogEnd, err := countIssues(jiraClient, openQuery)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stats := ServicedeskStats{
+		Project:    projectName,
+		From:       from,
+		To:         to,
+		Created:    len(created),
+		Resolved:   len(resolved),
+		BacklogEnd: backlogEnd,
+	}
+	// assumes tickets resolved in the window were open when it started, ignoring reopens
+	stats.BacklogStart = stats.BacklogEnd - stats.Created + stats.Resolved
+	stats.BacklogGrowth = stats.BacklogEnd - stats.BacklogStart
+
+	periods := map[string]*PeriodCount{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := statsPeriod(day, location)
+		if _, ok := periods[key]; !ok {
+			periods[key] = &PeriodCount{Period: key}
+			stats.Periods = append(stats.Periods, PeriodCount{Period: key})
+		}
+	}
+
+	requestTypes := map[string]int{}
+	priorities := map[string]int{}
+	assignees := map[string]int{}
+	reporters := map[string]int{}
+	organizations := map[string]int{}
+	for _, issue := range created {
+		if period, ok := periods[statsPeriod(time.Time(issue.Fields.Created), location)]; ok {
+			period.Created++
+		}
+
+		requestType := getRequestTypeName(&issue, requestTypeFieldID)
+		if requestType == "" {
+			requestType = "None"
+		}
+		requestTypes[requestType]++
+
+		priority := "None"
+		if issue.Fields.Priority != nil {
+			priority = issue.Fields.Priority.Name
+		}
+		priorities[priority]++
+
+		assignee := "Unassigned"
+		if issue.Fields.Assignee != nil {
+			assignee = issue.Fields.Assignee.DisplayName
+		}
+		assignees[assignee]++
+
+		if issue.Fields.Reporter != nil {
+			reporters[issue.Fields.Reporter.DisplayName]++
+		}
+		for _, organization := range getOrganizationNames(&issue, organizationsFieldID) {
+			organizations[organization]++
+		}
+	}
+
+	var resolutionTimes []time.Duration
+	for _, issue := range resolved {
+		resolvedAt := time.Time(issue.Fields.Resolutiondate)
+		if period, ok := periods[statsPeriod(resolvedAt, location)]; ok {
+			period.Resolved++
+		}
+		resolutionTimes = append(resolutionTimes, resolvedAt.Sub(time.Time(issue.Fields.Created)))
+	}
+	stats.MedianResolutionHours = percentile(resolutionTimes, 50).Hours()
+	stats.P90ResolutionHours = percentile(resolutionTimes, 90).Hours()
+
+	for i := range stats.Periods {
+		stats.Periods[i] = *periods[stats.Periods[i].Period]
+	}
+	stats.ByRequestType = sortedCounts(requestTypes, 0)
+	stats.ByPriority = sortedCounts(priorities, 0)
+	stats.ByAssignee = sortedCounts(assignees, 0)
+	stats.TopReporters = sortedCounts(reporters, StatsTop)
+	stats.TopOrganizations = sortedCounts(organizations, StatsTop)
+
+	return stats
+}
+
+// getStatsWindow returns the first and last day of the --from/--to window, or
+// the start of the last daysOfHistory days and now
+func getStatsWindow(daysOfHistory int, location *time.Location) (time.Time, time.Time) {
+	now := time.Now().In(location)
+	from := startOfDay(now).AddDate(0, 0, -daysOfHistory)
+	to := now
+	if ServicedeskFrom != "" || ServicedeskTo != "" {
+		from = time.Time{}
+		if ServicedeskFrom != "" {
+			from = parseServicedeskDate("--from", ServicedeskFrom, location)
+		}
+		if ServicedeskTo != "" {
+			to = parseServicedeskDate("--to", ServicedeskTo, location)
+		}
+	}
+	if from.IsZero() {
+		log.Fatal("--to needs a --from date for stats")
+	}
+	if from.After(to) {
+		log.Fatal("--from must not be after --to")
+	}
+	return from, to
+}
+
+// statsPeriod returns the day, or the Monday starting the week, that t falls in
+// in the given time zone
+func statsPeriod(t time.Time, location *time.Location) string {
+	t = t.In(location)
+	if StatsInterval == "week" {
+		return weekStart(t).Format("2006-01-02")
+	}
+	return startOfDay(t).Format("2006-01-02")
+}
+
+func hoursToDuration(hours float64) time.Duration {
+	return time.Duration(hours * float64(time.Hour))
+}
+
+func printStatsTable(stats ServicedeskStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Project\t%s\n", stats.Project)
+	fmt.Fprintf(w, "Window\t%s to %s\n", stats.From.Format("2006-01-02"), stats.To.Format("2006-01-02"))
+	fmt.Fprintf(w, "Created\t%d\n", stats.Created)
+	fmt.Fprintf(w, "Resolved\t%d\n", stats.Resolved)
+	fmt.Fprintf(w, "Backlog\t%d -> %d (%+d)\n", stats.BacklogStart, stats.BacklogEnd, stats.BacklogGrowth)
+	fmt.Fprintf(w, "Median time to resolution\t%s\n", formatDuration(hoursToDuration(stats.MedianResolutionHours)))
+	fmt.Fprintf(w, "90th percentile time to resolution\t%s\n", formatDuration(hoursToDuration(stats.P90ResolutionHours)))
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Period\tCreated\tResolved")
+	for _, period := range stats.Periods {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", period.Period, period.Created, period.Resolved)
+	}
+
+	breakdowns := []struct {
+		title   string
+		entries []countEntry
+	}{
+		{"Request Type", stats.ByRequestType},
+		{"Priority", stats.ByPriority},
+		{"Assignee", stats.ByAssignee},
+		{"Reporter", stats.TopReporters},
+		{"Organization", stats.TopOrganizations},
+	}
+	for _, breakdown := range breakdowns {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s\tTickets\n", breakdown.title)
+		for _, entry := range breakdown.entries {
+			fmt.Fprintf(w, "%s\t%d\n", entry.Name, entry.Count)
+		}
+	}
+	w.Flush()
+}
+
+func formatStatsMarkdown(stats ServicedeskStats) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s Service Desk Summary\n\n", stats.Project))
+	sb.WriteString(fmt.Sprintf("%s to %s\n\n", stats.From.Format("2006-01-02"), stats.To.Format("2006-01-02")))
+	sb.WriteString(fmt.Sprintf("- **Created:** %d\n", stats.Created))
+	sb.WriteString(fmt.Sprintf("- **Resolved:** %d\n", stats.Resolved))
+	sb.WriteString(fmt.Sprintf("- **Backlog:** %d → %d (%+d)\n", stats.BacklogStart, stats.BacklogEnd, stats.BacklogGrowth))
+	sb.WriteString(fmt.Sprintf("- **Median time to resolution:** %s\n", formatDuration(hoursToDuration(stats.MedianResolutionHours))))
+	sb.WriteString(fmt.Sprintf("- **90th percentile time to resolution:** %s\n\n", formatDuration(hoursToDuration(stats.P90ResolutionHours))))
+
+	sb.WriteString("## Volume\n\n| Period | Created | Resolved |\n|---|---|---|\n")
+	for _, period := range stats.Periods {
+		sb.WriteString(fmt.Sprintf("| %s | %d | %d |\n", period.Period, period.Created, period.Resolved))
+	}
+
+	breakdowns := []struct {
+		title   string
+		entries []countEntry
+	}{
+		{"Request Type", stats.ByRequestType},
+		{"Priority", stats.ByPriority},
+		{"Assignee", stats.ByAssignee},
+		{"Top Reporters", stats.TopReporters},
+		{"Top Organizations", stats.TopOrganizations},
+	}
+	for _, breakdown := range breakdowns {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n| Name | Tickets |\n|---|---|\n", breakdown.title))
+		for _, entry := range breakdown.entries {
+			sb.WriteString(fmt.Sprintf("| %s | %d |\n", entry.Name, entry.Count))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,175 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// SLA states reported for each request
+const (
+	slaBreached   = "Breached"
+	slaAtRisk     = "At Risk"
+	slaMet        = "Met"
+	slaOnTrack    = "On Track"
+	slaNotStarted = "Not Started"
+)
+
+// SLADuration is a duration as returned by the Service Management API
+type SLADuration struct {
+	Millis   int64  `json:"millis"`
+	Friendly string `json:"friendly"`
+}
+
+// SLACycle is one cycle of an SLA, either completed or still running
+type SLACycle struct {
+	Breached      bool        `json:"breached"`
+	Paused        bool        `json:"paused"`
+	GoalDuration  SLADuration `json:"goalDuration"`
+	ElapsedTime   SLADuration `json:"elapsedTime"`
+	RemainingTime SLADuration `json:"remainingTime"`
+}
+
+// RequestSLA is an SLA metric such as "Time to first response" for a single request
+type RequestSLA struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	OngoingCycle    *SLACycle  `json:"ongoingCycle"`
+	CompletedCycles []SLACycle `json:"completedCycles"`
+}
+
+type requestSLAPage struct {
+	Values     []RequestSLA `json:"values"`
+	IsLastPage bool         `json:"isLastPage"`
+	Start      int          `json:"start"`
+	Size       int          `json:"size"`
+}
+
+// getRequestSLAs fetches the SLA metrics of a service desk request
+func getRequestSLAs(jiraClient *jira.Client, issueKey string) []RequestSLA {
+	var slas []RequestSLA
+	start := 0
+	for {
+		endpoint := fmt.Sprintf("rest/servicedeskapi/request/%s/sla?start=%d", issueKey, start)
+		req, err := jiraClient.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var page requestSLAPage
+		if _, err := jiraClient.Do(req, &page); err != nil {
+			log.Fatalf("Couldn't get the SLAs for %s: %s", issueKey, err)
+		}
+		slas = append(slas, page.Values...)
+		if page.IsLastPage || page.Size == 0 {
+			break
+		}
+		start += page.Size
+	}
+	return slas
+}
+
+// State returns whether the SLA is breached, at risk, met, on track or not started.
+// A running cycle is at risk once less than atRisk remains, unless it is paused.
+func (s RequestSLA) State(atRisk time.Duration) string {
+	for _, cycle := range s.CompletedCycles {
+		if cycle.Breached {
+			return slaBreached
+		}
+	}
+	if s.OngoingCycle == nil {
+		if len(s.CompletedCycles) == 0 {
+			return slaNotStarted
+		}
+		return slaMet
+	}
+	if s.OngoingCycle.Breached {
+		return slaBreached
+	}
+	if !s.OngoingCycle.Paused && time.Duration(s.OngoingCycle.RemainingTime.Millis)*time.Millisecond < atRisk {
+		return slaAtRisk
+	}
+	return slaOnTrack
+}
+
+// currentCycle returns the running cycle, or the last completed one
+func (s RequestSLA) currentCycle() *SLACycle {
+	if s.OngoingCycle != nil {
+		return s.OngoingCycle
+	}
+	if len(s.CompletedCycles) > 0 {
+		return &s.CompletedCycles[len(s.CompletedCycles)-1]
+	}
+	return nil
+}
+
+// Elapsed returns the elapsed time of the current cycle
+func (s RequestSLA) Elapsed() string {
+	if cycle := s.currentCycle(); cycle != nil {
+		return cycle.ElapsedTime.Friendly
+	}
+	return ""
+}
+
+// Remaining returns the time left in the current cycle, negative once breached
+func (s RequestSLA) Remaining() string {
+	if cycle := s.currentCycle(); cycle != nil {
+		return cycle.RemainingTime.Friendly
+	}
+	return ""
+}
+
+// isBreached reports whether any of the SLAs have been breached
+func isBreached(slas []RequestSLA) bool {
+	for _, sla := range slas {
+		if sla.State(0) == slaBreached {
+			return true
+		}
+	}
+	return false
+}
+
+// getSLANames returns the SLA names across all requests in the order they were first seen
+func getSLANames(slasByIssue map[string][]RequestSLA, issues []jira.Issue) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, issue := range issues {
+		for _, sla := range slasByIssue[issue.Key] {
+			if !seen[sla.Name] {
+				seen[sla.Name] = true
+				names = append(names, sla.Name)
+			}
+		}
+	}
+	return names
+}
+
+// printSLASummary prints the number of breached, at risk, met, on track and not started requests for each SLA
+func printSLASummary(slasByIssue map[string][]RequestSLA, issues []jira.Issue, atRisk time.Duration, out func(format string, a ...interface{})) {
+	for _, name := range getSLANames(slasByIssue, issues) {
+		counts := map[string]int{}
+		for _, issue := range issues {
+			for _, sla := range slasByIssue[issue.Key] {
+				if sla.Name == name {
+					counts[sla.State(atRisk)]++
+				}
+			}
+		}
+		out("%s: %d breached, %d at risk, %d met, %d on track, %d not started\n", name, counts[slaBreached], counts[slaAtRisk], counts[slaMet], counts[slaOnTrack], counts[slaNotStarted])
+	}
+}
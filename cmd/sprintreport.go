@@ -0,0 +1,403 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// SprintData struct
+type SprintData struct {
+	Name             string
+	Start            time.Time
+	End              time.Time
+	CommittedIssues  []IssuePrinted
+	AddedIssues      []IssuePrinted
+	RemovedIssues    []IssuePrinted
+	CompletedIssues  []IssuePrinted
+	IncompleteIssues []IssuePrinted
+	CarriedOver      []IssuePrinted
+	IssueTypes       []string
+}
+
+// IssuePrinted struct
+type IssuePrinted struct {
+	JiraIssue jira.Issue
+	Printed   string
+	Points    float64
+}
+
+// sprintChange is an issue being added to or removed from a sprint
+type sprintChange struct {
+	At time.Time
+	In bool
+}
+
+// SprintBoard is the name or id of the board the sprint belongs to
+var SprintBoard string
+
+// SprintName selects the sprint: current, last, or a sprint id or name
+var SprintName string
+
+// sprintCmd represents the sprint command
+var sprintCmd = &cobra.Command{
+	Use:   "sprint",
+	Short: "Reports on the sprints of a board",
+}
+
+// sprintReportCmd represents the sprint report command
+var sprintReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Committed vs completed work, scope change and carry-over for a sprint",
+	Long: `Replays the Sprint field history of the sprint's issues to list the issues
+committed when the sprint started, those added or removed while it ran, which
+were completed and which were carried over to another sprint, with story
+points for each.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if SprintBoard == "" {
+			log.Fatal("You must specify a board with the -b or --board string flag")
+		}
+		jiraClient, url := jirasetup.GetJiraClient()
+		board := getBoard(jiraClient, SprintBoard)
+		sprint := findSprint(jiraClient, board, SprintName)
+		fmt.Print(formatSprintReport(getSprintData(jiraClient, board, sprint, url)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sprintCmd)
+	sprintCmd.AddCommand(sprintReportCmd)
+
+	sprintCmd.PersistentFlags().StringVarP(&SprintBoard, "board", "b", "", "name or id of the board")
+	sprintCmd.PersistentFlags().StringVarP(&SprintName, "sprint", "s", "current", "current, last, or a sprint id or name")
+}
+
+// getBoard finds a board by id or by name
+func getBoard(jiraClient *jira.Client, nameOrID string) jira.Board {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		board, _, err := jiraClient.Board.GetBoard(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return *board
+	}
+
+	boards, _, err := jiraClient.Board.GetAllBoards(&jira.BoardListOptions{Name: nameOrID})
+	if err != nil {
+		log.Fatal(err)
+	}
+	var names []string
+	for _, board := range boards.Values {
+		if strings.EqualFold(board.Name, nameOrID) {
+			return board
+		}
+		names = append(names, board.Name)
+	}
+	if len(boards.Values) == 1 {
+		return boards.Values[0]
+	}
+	if len(names) == 0 {
+		log.Fatalf("No board named %q", nameOrID)
+	}
+	log.Fatalf("More than one board matches %q: %s", nameOrID, strings.Join(names, ", "))
+	return jira.Board{}
+}
+
+// getBoardSprints returns the board's sprints in the given states (e.g. "active,closed"), oldest first
+func getBoardSprints(jiraClient *jira.Client, boardID int, state string) []jira.Sprint {
+	var sprints []jira.Sprint
+	for {
+		page, _, err := jiraClient.Board.GetAllSprintsWithOptions(boardID, &jira.GetAllSprintsOptions{
+			State:         state,
+			SearchOptions: jira.SearchOptions{StartAt: len(sprints), MaxResults: 50},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		sprints = append(sprints, page.Values...)
+		if page.IsLast || len(page.Values) == 0 {
+			break
+		}
+	}
+	return sprints
+}
+
+func sprintTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// findSprint picks the board's active sprint, its most recently closed sprint,
+// or a sprint by id or name
+func findSprint(jiraClient *jira.Client, board jira.Board, which string) jira.Sprint {
+	switch strings.ToLower(which) {
+	case "current", "":
+		sprints := getBoardSprints(jiraClient, board.ID, "active")
+		if len(sprints) == 0 {
+			log.Fatalf("%s has no active sprint", board.Name)
+		}
+		return sprints[len(sprints)-1]
+	case "last":
+		sprints := getBoardSprints(jiraClient, board.ID, "closed")
+		if len(sprints) == 0 {
+			log.Fatalf("%s has no closed sprints", board.Name)
+		}
+		sort.SliceStable(sprints, func(i, j int) bool {
+			return sprintTime(sprints[i].CompleteDate).Before(sprintTime(sprints[j].CompleteDate))
+		})
+		return sprints[len(sprints)-1]
+	}
+
+	id, _ := strconv.Atoi(which)
+	for _, sprint := range getBoardSprints(jiraClient, board.ID, "") {
+		if sprint.ID == id || strings.EqualFold(sprint.Name, which) {
+			return sprint
+		}
+	}
+	log.Fatalf("%s has no sprint %q", board.Name, which)
+	return jira.Sprint{}
+}
+
+// sprintIDs parses the comma separated sprint ids recorded in the changelog
+func sprintIDs(value interface{}) map[int]bool {
+	ids := map[int]bool{}
+	s, _ := value.(string)
+	for _, field := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// getSprintChanges returns whether an issue was in the sprint when it was created and
+// the times it was added to or removed from the sprint since, oldest first
+func getSprintChanges(issue *jira.Issue, sprintID int, inSprintNow bool) (bool, []sprintChange) {
+	type sprintItem struct {
+		At       time.Time
+		From, To map[int]bool
+	}
+	var items []sprintItem
+	if issue.Changelog != nil {
+		for _, history := range issue.Changelog.Histories {
+			at, err := history.CreatedTime()
+			if err != nil {
+				continue
+			}
+			for _, item := range history.Items {
+				if strings.EqualFold(item.Field, "Sprint") {
+					items = append(items, sprintItem{at, sprintIDs(item.From), sprintIDs(item.To)})
+				}
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.Before(items[j].At) })
+
+	var changes []sprintChange
+	if len(items) == 0 {
+		return inSprintNow, changes
+	}
+	for _, item := range items {
+		if item.From[sprintID] != item.To[sprintID] {
+			changes = append(changes, sprintChange{item.At, item.To[sprintID]})
+		}
+	}
+	return items[0].From[sprintID], changes
+}
+
+// inSprintAt tells whether the issue was in the sprint at time t
+func inSprintAt(created time.Time, initial bool, changes []sprintChange, t time.Time) bool {
+	if t.Before(created) {
+		return false
+	}
+	in := initial
+	for _, change := range changes {
+		if change.At.After(t) {
+			break
+		}
+		in = change.In
+	}
+	return in
+}
+
+// getStatusCategoryAt returns the status category key of an issue at time t
+func getStatusCategoryAt(issue *jira.Issue, categories map[string]string, t time.Time) string {
+	changes := getStatusChanges(issue)
+	id, _ := getInitialStatus(issue, changes)
+	for _, change := range changes {
+		if change.At.After(t) {
+			break
+		}
+		id = change.ToID
+	}
+	return categories[id]
+}
+
+// getSprintData sorts the issues that were ever in the sprint into committed, added,
+// removed, completed, incomplete and carried over
+func getSprintData(jiraClient *jira.Client, board jira.Board, sprint jira.Sprint, baseURL string) SprintData {
+	data := SprintData{
+		Name:  sprint.Name,
+		Start: sprintTime(sprint.StartDate),
+		End:   sprintTime(sprint.CompleteDate),
+	}
+	if data.Start.IsZero() {
+		log.Fatalf("%s hasn't started yet", sprint.Name)
+	}
+	if data.End.IsZero() {
+		data.End = time.Now()
+	}
+
+	sprintFieldID := getCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+	pointsFieldID := getStoryPointsFieldID(jiraClient)
+
+	// issues removed from the sprint are no longer returned by sprint = id, so
+	// also look at everything on the board that changed while the sprint ran
+	query := jql.New()
+	alternatives := []*jql.Query{jql.New().Where("sprint", "=", jql.Number(sprint.ID))}
+	if config, _, err := jiraClient.Board.GetBoardConfiguration(board.ID); err == nil {
+		if filterID, err := strconv.Atoi(config.Filter.ID); err == nil {
+			alternatives = append(alternatives, jql.New().
+				Where("filter", "=", jql.Number(filterID)).
+				Where("updated", ">=", jql.String(jqlTime(data.Start.In(getProfileLocation(jiraClient))))))
+		}
+	}
+	query.Any(alternatives...).OrderBy("rank", false)
+
+	fields := []string{"summary", "issuetype", "status", "assignee", "created", sprintFieldID}
+	if pointsFieldID != "" {
+		fields = append(fields, pointsFieldID)
+	}
+	issues := searchAllIssues(jiraClient, query.String(), &jira.SearchOptions{Expand: "changelog", Fields: fields})
+	categories := getStatusCategories(jiraClient)
+
+	issueTypes := map[string]bool{}
+	for i := range issues {
+		issue := &issues[i]
+		sprintNames := getSprintNames(issue, sprintFieldID)
+		inSprintNow := false
+		for _, name := range sprintNames {
+			inSprintNow = inSprintNow || name == sprint.Name
+		}
+		created := time.Time(issue.Fields.Created)
+		initial, changes := getSprintChanges(issue, sprint.ID, inSprintNow)
+
+		atStart := inSprintAt(created, initial, changes, data.Start)
+		atEnd := inSprintAt(created, initial, changes, data.End)
+		during := atStart || atEnd
+		for _, change := range changes {
+			if change.In && change.At.After(data.Start) && change.At.Before(data.End) {
+				during = true
+			}
+		}
+		if !during {
+			continue
+		}
+
+		printed := IssuePrinted{
+			JiraIssue: *issue,
+			Printed:   strings.TrimSuffix(getPrintedIssue(issue, baseURL), "\n"),
+			Points:    getStoryPoints(issue, pointsFieldID),
+		}
+		issueTypes[issue.Fields.Type.Name] = true
+
+		if atStart {
+			data.CommittedIssues = append(data.CommittedIssues, printed)
+		} else {
+			data.AddedIssues = append(data.AddedIssues, printed)
+		}
+		if !atEnd {
+			data.RemovedIssues = append(data.RemovedIssues, printed)
+			continue
+		}
+		if getStatusCategoryAt(issue, categories, data.End) == "done" {
+			data.CompletedIssues = append(data.CompletedIssues, printed)
+			continue
+		}
+		data.IncompleteIssues = append(data.IncompleteIssues, printed)
+
+		// the issue's sprint field lists the sprints it has been in, oldest first
+		if last := len(sprintNames) - 1; last >= 0 && sprintNames[last] != sprint.Name {
+			printed.Printed += " -> " + sprintNames[last]
+			data.CarriedOver = append(data.CarriedOver, printed)
+		}
+	}
+
+	for issueType := range issueTypes {
+		data.IssueTypes = append(data.IssueTypes, issueType)
+	}
+	sort.Strings(data.IssueTypes)
+	return data
+}
+
+func sumPoints(issues []IssuePrinted) float64 {
+	var total float64
+	for _, issue := range issues {
+		total += issue.Points
+	}
+	return total
+}
+
+func formatSprintSection(title string, issues []IssuePrinted) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n## %s (%d issues, %g points)\n", title, len(issues), sumPoints(issues)))
+	if len(issues) == 0 {
+		sb.WriteString("- None\n")
+	}
+	for _, issue := range issues {
+		sb.WriteString(fmt.Sprintf("%s -- %g points\n", issue.Printed, issue.Points))
+	}
+	return sb.String()
+}
+
+func formatSprintReport(data SprintData) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n", data.Name))
+	sb.WriteString(fmt.Sprintf("%s to %s\n", data.Start.Local().Format("2006-01-02"), data.End.Local().Format("2006-01-02")))
+
+	committed, completed := sumPoints(data.CommittedIssues), sumPoints(data.CompletedIssues)
+	sb.WriteString(fmt.Sprintf("\nCommitted: %d issues, %g points\n", len(data.CommittedIssues), committed))
+	sb.WriteString(fmt.Sprintf("Added: %d issues, %g points\n", len(data.AddedIssues), sumPoints(data.AddedIssues)))
+	sb.WriteString(fmt.Sprintf("Removed: %d issues, %g points\n", len(data.RemovedIssues), sumPoints(data.RemovedIssues)))
+	sb.WriteString(fmt.Sprintf("Completed: %d issues, %g points", len(data.CompletedIssues), completed))
+	if committed > 0 {
+		sb.WriteString(fmt.Sprintf(" (%.0f%% of committed points)", completed/committed*100))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Carried over: %d issues, %g points\n", len(data.CarriedOver), sumPoints(data.CarriedOver)))
+	if len(data.IssueTypes) > 0 {
+		sb.WriteString(fmt.Sprintf("Issue types: %s\n", strings.Join(data.IssueTypes, ", ")))
+	}
+
+	sb.WriteString(formatSprintSection("Committed", data.CommittedIssues))
+	sb.WriteString(formatSprintSection("Added During the Sprint", data.AddedIssues))
+	sb.WriteString(formatSprintSection("Removed During the Sprint", data.RemovedIssues))
+	sb.WriteString(formatSprintSection("Completed", data.CompletedIssues))
+	sb.WriteString(formatSprintSection("Incomplete", data.IncompleteIssues))
+	sb.WriteString(formatSprintSection("Carried Over", data.CarriedOver))
+	return sb.String()
+}
@@ -0,0 +1,309 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// StandupItem holds what a user did on an issue during the standup window
+type StandupItem struct {
+	Issue       jira.Issue
+	Transitions []string
+	Comments    int
+	TimeLogged  int
+}
+
+// StandupReport holds one user's standup sections
+type StandupReport struct {
+	User       string
+	Done       []StandupItem
+	InProgress []StandupItem
+	Blocked    []StandupItem
+}
+
+// StandupUser is the user to report on, defaults to the current user
+var StandupUser string
+
+// StandupTeam is a comma-separated list of users to report on
+var StandupTeam string
+
+// StandupFormat is the output format: markdown or slack
+var StandupFormat string
+
+// standupCmd represents the standup command
+var standupCmd = &cobra.Command{
+	Use:   "standup",
+	Short: "Generates a daily standup report",
+	Long: `Looks at the status changes, comments and worklogs on issues touched since
+the start of the last working day (skipping weekends) and reports what is done,
+what is in progress and what is blocked.
+
+By default the report is for the current user. Use --user for someone else or
+--team for a comma-separated list of users.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if StandupFormat != "markdown" && StandupFormat != "slack" {
+			log.Fatal("--format must be markdown or slack")
+		}
+
+		jiraClient, url := jirasetup.GetJiraClient()
+
+		users := jql.Split(StandupTeam)
+		if len(users) == 0 {
+			users = []string{StandupUser}
+		}
+
+		// JQL reads dates in the profile time zone, so the last working day is found in it too
+		since := previousWorkingDay(time.Now().In(getProfileLocation(jiraClient)))
+		var sb strings.Builder
+		for _, user := range users {
+			report := getStandupReport(jiraClient, user, since)
+			sb.WriteString(formatStandupReport(report, url, len(users) > 1))
+		}
+		fmt.Print(sb.String())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(standupCmd)
+
+	standupCmd.PersistentFlags().StringVarP(&StandupUser, "user", "u", "", "user to report on (defaults to the current user)")
+	standupCmd.PersistentFlags().StringVarP(&StandupTeam, "team", "t", "", "comma-separated list of users to report on")
+	standupCmd.PersistentFlags().StringVar(&StandupFormat, "format", "markdown", "output format: markdown or slack")
+}
+
+// standupUserValue returns the user as it should appear in a query
+func standupUserValue(user string) jql.Value {
+	if user == "" {
+		return jql.MustFunc("currentUser")
+	}
+	return jql.String(user)
+}
+
+func getStandupReport(jiraClient *jira.Client, user string, since time.Time) StandupReport {
+	who := user
+	if who == "" {
+		self, _, err := jiraClient.User.GetSelf()
+		if err != nil {
+			log.Fatal(err)
+		}
+		who = self.DisplayName
+		user = self.AccountID
+		if user == "" {
+			user = self.Name
+		}
+	}
+	report := StandupReport{User: who}
+	userValue := standupUserValue(user)
+
+	touchedQuery := jql.New().
+		Where("updated", ">=", jql.String(jqlTime(since))).
+		Any(
+			jql.New().Where("assignee", "=", userValue),
+			jql.New().Where("worklogAuthor", "=", userValue),
+			jql.New().Where("watcher", "=", userValue),
+			jql.New().Raw("status changed by "+userValue.String()+" after "+jql.Quote(jqlTime(since))),
+		).
+		OrderBy("updated", true)
+
+	searchOpts := &jira.SearchOptions{
+		Expand: "changelog",
+		Fields: []string{"*navigable", "comment", "worklog"},
+	}
+	touched := searchAllIssues(jiraClient, touchedQuery.String(), searchOpts)
+
+	seen := map[string]bool{}
+	for _, issue := range touched {
+		item := getStandupItem(issue, user, since)
+		if len(item.Transitions) == 0 && item.Comments == 0 && item.TimeLogged == 0 {
+			continue
+		}
+		seen[issue.Key] = true
+		report.add(item)
+	}
+
+	// anything still in progress is what's being worked on today
+	inProgressQuery := jql.New().
+		Where("assignee", "=", userValue).
+		Eq("statusCategory", "In Progress").
+		OrderBy("updated", true)
+	for _, issue := range searchAllIssues(jiraClient, inProgressQuery.String(), nil) {
+		if !seen[issue.Key] {
+			report.add(StandupItem{Issue: issue})
+		}
+	}
+
+	return report
+}
+
+func (r *StandupReport) add(item StandupItem) {
+	switch {
+	case isBlocked(&item.Issue):
+		r.Blocked = append(r.Blocked, item)
+	case item.Issue.Fields.Status.StatusCategory.Key == "done":
+		r.Done = append(r.Done, item)
+	default:
+		r.InProgress = append(r.InProgress, item)
+	}
+}
+
+// getStandupItem collects the status changes, comments and worklogs made by the user since the given time
+func getStandupItem(issue jira.Issue, user string, since time.Time) StandupItem {
+	item := StandupItem{Issue: issue}
+
+	if issue.Changelog != nil {
+		for _, history := range issue.Changelog.Histories {
+			created, err := history.CreatedTime()
+			if err != nil || created.Before(since) || !isUser(&history.Author, user) {
+				continue
+			}
+			for _, change := range history.Items {
+				if change.Field == "status" {
+					item.Transitions = append(item.Transitions, change.FromString+" → "+change.ToString)
+				}
+			}
+		}
+	}
+
+	if issue.Fields.Comments != nil {
+		for _, comment := range issue.Fields.Comments.Comments {
+			created, err := parseJiraTime(comment.Created)
+			if err == nil && !created.Before(since) && isUser(&comment.Author, user) {
+				item.Comments++
+			}
+		}
+	}
+
+	if issue.Fields.Worklog != nil {
+		for _, worklog := range issue.Fields.Worklog.Worklogs {
+			if worklog.Started == nil || time.Time(*worklog.Started).Before(since) || worklog.Author == nil || !isUser(worklog.Author, user) {
+				continue
+			}
+			item.TimeLogged += worklog.TimeSpentSeconds
+		}
+	}
+
+	return item
+}
+
+// isUser reports whether a Jira user matches the account id, username, email or display name given
+func isUser(u *jira.User, user string) bool {
+	for _, id := range []string{u.AccountID, u.Name, u.Key, u.EmailAddress, u.DisplayName} {
+		if id != "" && strings.EqualFold(id, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlocked reports whether the issue is in a blocked status, flagged, or blocked by an unfinished issue
+func isBlocked(issue *jira.Issue) bool {
+	if strings.Contains(strings.ToLower(issue.Fields.Status.Name), "block") {
+		return true
+	}
+	for name, value := range issue.Fields.Unknowns {
+		if strings.HasPrefix(name, "customfield_") {
+			if flags, ok := value.([]interface{}); ok {
+				for _, flag := range flags {
+					if f, ok := flag.(map[string]interface{}); ok && f["value"] == "Impediment" {
+						return true
+					}
+				}
+			}
+		}
+	}
+	for _, link := range issue.Fields.IssueLinks {
+		if link.Type.Name != "Blocks" || link.InwardIssue == nil || link.InwardIssue.Fields == nil {
+			continue
+		}
+		if link.InwardIssue.Fields.Status != nil && link.InwardIssue.Fields.Status.StatusCategory.Key != "done" {
+			return true
+		}
+	}
+	return false
+}
+
+func formatStandupReport(report StandupReport, baseURL string, showUser bool) string {
+	var sb strings.Builder
+	heading := func(title string) {
+		if StandupFormat == "slack" {
+			sb.WriteString("*" + title + "*\n")
+		} else {
+			sb.WriteString("### " + title + "\n\n")
+		}
+	}
+
+	if showUser {
+		if StandupFormat == "slack" {
+			sb.WriteString("*" + report.User + "*\n\n")
+		} else {
+			sb.WriteString("## " + report.User + "\n\n")
+		}
+	}
+
+	sections := []struct {
+		title string
+		items []StandupItem
+	}{
+		{"Done", report.Done},
+		{"In Progress", report.InProgress},
+		{"Blocked", report.Blocked},
+	}
+	for _, section := range sections {
+		heading(section.title)
+		if len(section.items) == 0 {
+			sb.WriteString("- Nothing\n\n")
+			continue
+		}
+		for _, item := range section.items {
+			sb.WriteString(formatStandupItem(item, baseURL))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func formatStandupItem(item StandupItem, baseURL string) string {
+	var notes []string
+	if len(item.Transitions) > 0 {
+		notes = append(notes, "moved "+strings.Join(item.Transitions, ", "))
+	}
+	if item.Comments == 1 {
+		notes = append(notes, "1 comment")
+	} else if item.Comments > 1 {
+		notes = append(notes, fmt.Sprintf("%d comments", item.Comments))
+	}
+	if item.TimeLogged > 0 {
+		notes = append(notes, "logged "+formatSeconds(item.TimeLogged))
+	}
+	detail := ""
+	if len(notes) > 0 {
+		detail = " (" + strings.Join(notes, "; ") + ")"
+	}
+
+	link := browseURL(baseURL, item.Issue.Key)
+	if StandupFormat == "slack" {
+		return fmt.Sprintf("• <%s|%s> %s%s\n", link, item.Issue.Key, item.Issue.Fields.Summary, detail)
+	}
+	return fmt.Sprintf("- [%s](%s) %s%s\n", item.Issue.Key, link, item.Issue.Fields.Summary, detail)
+}
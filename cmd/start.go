@@ -0,0 +1,295 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultBranchTemplate = "feature/{{.Key}}-{{slug .Summary}}"
+
+// prepareCommitMsgHook prefixes commit messages with the first issue key in the branch name
+const prepareCommitMsgHook = `#!/bin/sh
+# Installed by jira-tools: prefixes commit messages with the Jira issue key in the branch name.
+
+# leave merges, squashes and amended commits alone
+case "$2" in
+merge|squash|commit) exit 0 ;;
+esac
+
+branch=$(git symbolic-ref --short HEAD 2>/dev/null) || exit 0
+# branch templates may lower case the key, e.g. {{lower .Key}}
+key=$(printf '%s' "$branch" | grep -oiE '[a-z][a-z0-9_]+-[0-9]+' | head -n 1 | tr '[:lower:]' '[:upper:]')
+[ -n "$key" ] || exit 0
+
+# don't add the key twice
+head -n 1 "$1" | grep -qiF "$key" && exit 0
+
+sed "1s/^/$key: /" "$1" > "$1.jira-tools" && mv "$1.jira-tools" "$1"
+`
+
+var slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// branchData is what branch name templates can use
+type branchData struct {
+	Key     string
+	Summary string
+	Type    string
+	Project string
+}
+
+// StartStatus is the status to move the issue to, defaults to the first In Progress status
+var StartStatus string
+
+// StartBranchTemplate is the template for the branch name
+var StartBranchTemplate string
+
+// StartNoBranch skips creating the git branch
+var StartNoBranch bool
+
+// HookForce overwrites an existing prepare-commit-msg hook
+var HookForce bool
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Use:   "start [KEY]",
+	Short: "Starts work on an issue",
+	Long: `Assigns the issue to you, moves it to In Progress and creates a git branch
+for it in the current repository, or checks the branch out if it exists. Without
+a key, the issue is chosen with the fuzzy finder from your unfinished issues.
+
+The branch name comes from --branch-template, the branch_template setting in the
+config file, or ` + defaultBranchTemplate + `. Templates can use .Key,
+.Summary, .Type and .Project, and the slug, lower and upper functions.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		jiraClient, _ := jirasetup.GetJiraClient()
+
+		var key string
+		if len(args) == 1 {
+			key = strings.ToUpper(args[0])
+		} else {
+			query := jql.New().
+				Where("assignee", "=", jql.MustFunc("currentUser")).
+				Where("statusCategory", "!=", jql.String("Done")).
+				OrderBy("updated", true)
+			issues := searchAllIssues(jiraClient, query.String(), &jira.SearchOptions{Fields: []string{"summary", "status"}})
+			if len(issues) == 0 {
+				log.Fatal("You have no unfinished issues, give the key of the issue to start")
+			}
+			index, err := pickIssue(issues, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if index < 0 {
+				os.Exit(1)
+			}
+			key = issues[index].Key
+		}
+
+		issue, resp, err := jiraClient.Issue.Get(key, &jira.GetQueryOptions{Fields: "summary,status,issuetype,project,assignee"})
+		if err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+
+		// check the repository and render the branch name first so a bad template or
+		// directory doesn't leave the issue half started
+		branch := ""
+		if !StartNoBranch {
+			if _, err := gitOutput("rev-parse", "--is-inside-work-tree"); err != nil {
+				log.Fatal("Not in a git repository, use --no-branch to only update the issue")
+			}
+			branch, err = branchName(issue)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err := assignToSelf(jiraClient, issue); err != nil {
+			log.Fatal(err)
+		}
+		if err := startProgress(jiraClient, issue); err != nil {
+			log.Fatal(err)
+		}
+		if branch != "" {
+			if err := checkoutBranch(branch); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+// installHookCmd represents the install-hook command
+var installHookCmd = &cobra.Command{
+	Use:   "install-hook",
+	Short: "Installs a git hook that adds the issue key to commit messages",
+	Long: `Installs a prepare-commit-msg hook in the current repository that prefixes
+commit messages with the issue key in the branch name, e.g. "ABC-123: Fix login"
+on feature/ABC-123-login-fails. Messages that already have the key, merges and
+amended commits are left alone.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := gitOutput("rev-parse", "--git-path", "hooks")
+		if err != nil {
+			log.Fatal("Not in a git repository")
+		}
+		if err := os.MkdirAll(hooks, 0755); err != nil {
+			log.Fatal(err)
+		}
+		path := hooks + "/prepare-commit-msg"
+		if existing, err := os.ReadFile(path); err == nil && string(existing) != prepareCommitMsgHook && !HookForce {
+			log.Fatalf("%s already exists, use --force to replace it", path)
+		}
+		if err := os.WriteFile(path, []byte(prepareCommitMsgHook), 0755); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Installed %s\n", path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(installHookCmd)
+
+	startCmd.Flags().StringVar(&StartStatus, "status", "", "status or transition to move the issue to (default the first In Progress status)")
+	startCmd.Flags().StringVar(&StartBranchTemplate, "branch-template", "", "template for the branch name (default \""+defaultBranchTemplate+"\")")
+	startCmd.Flags().BoolVar(&StartNoBranch, "no-branch", false, "don't create a git branch")
+	installHookCmd.Flags().BoolVarP(&HookForce, "force", "f", false, "replace an existing prepare-commit-msg hook")
+}
+
+// slug turns text into lowercase words joined by dashes, cut at a word boundary after 50 characters
+func slug(text string) string {
+	words := strings.Split(strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(text), "-"), "-"), "-")
+	result := ""
+	for _, word := range words {
+		if result != "" && len(result)+1+len(word) > 50 {
+			break
+		}
+		if result != "" {
+			result += "-"
+		}
+		result += word
+	}
+	return result
+}
+
+// branchName renders the branch template for the issue
+func branchName(issue *jira.Issue) (string, error) {
+	text := StartBranchTemplate
+	if text == "" {
+		text = viper.GetString("branch_template")
+	}
+	if text == "" {
+		text = defaultBranchTemplate
+	}
+	tmpl, err := template.New("branch").Funcs(template.FuncMap{
+		"slug":  slug,
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid branch template: %s", err)
+	}
+
+	data := branchData{
+		Key:     issue.Key,
+		Summary: issue.Fields.Summary,
+		Type:    issue.Fields.Type.Name,
+		Project: issue.Fields.Project.Key,
+	}
+	var name bytes.Buffer
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("invalid branch template: %s", err)
+	}
+	if _, err := gitOutput("check-ref-format", "--branch", name.String()); err != nil {
+		return "", fmt.Errorf("%q is not a valid branch name", name.String())
+	}
+	return name.String(), nil
+}
+
+// assignToSelf assigns the issue to the current user unless it already is
+func assignToSelf(jiraClient *jira.Client, issue *jira.Issue) error {
+	self, err := lookupUser(jiraClient, "me")
+	if err != nil {
+		return err
+	}
+	if assignee := issue.Fields.Assignee; assignee != nil && worklogUserID(*assignee) == worklogUserID(*self) {
+		return nil
+	}
+	if resp, err := jiraClient.Issue.UpdateAssignee(issue.Key, &jira.User{AccountID: self.AccountID, Name: self.Name}); err != nil {
+		return jira.NewJiraError(resp, err)
+	}
+	color.Green("%s assigned to %s", issue.Key, self.DisplayName)
+	return nil
+}
+
+// startProgress moves the issue to --status, or through the first transition into an
+// In Progress status when the issue isn't in one already
+func startProgress(jiraClient *jira.Client, issue *jira.Issue) error {
+	target := StartStatus
+	if target == "" {
+		if issue.Fields.Status.StatusCategory.Key == "indeterminate" {
+			fmt.Printf("%s is already in %s\n", issue.Key, issue.Fields.Status.Name)
+			return nil
+		}
+		transitions, err := getTransitions(jiraClient, issue.Key)
+		if err != nil {
+			return err
+		}
+		for _, transition := range transitions {
+			if transition.To.StatusCategory.Key == "indeterminate" {
+				target = transition.Name
+				break
+			}
+		}
+		if target == "" {
+			return fmt.Errorf("%s has no transition to an In Progress status, choose one with --status", issue.Key)
+		}
+	}
+
+	result := moveIssue(jiraClient, issue.Key, target)
+	printMoveResult(result)
+	return result.Err
+}
+
+// checkoutBranch creates the branch from the current commit, or checks it out if it exists
+func checkoutBranch(branch string) error {
+	args := []string{"checkout", "-b", branch}
+	if _, err := gitOutput("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		args = []string{"checkout", branch}
+	}
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Stdout, gitCmd.Stderr = os.Stdout, os.Stderr
+	return gitCmd.Run()
+}
+
+// gitOutput runs git and returns its trimmed output
+func gitOutput(args ...string) (string, error) {
+	output, err := exec.Command("git", args...).Output()
+	return strings.TrimSpace(string(output)), err
+}
@@ -0,0 +1,60 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// percentile returns the pth percentile (0-100) of the durations using the
+// nearest-rank method. The durations are sorted in place.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(durations))))
+	if rank < 1 {
+		rank = 1
+	}
+	return durations[rank-1]
+}
+
+// countEntry is a name and how many times it occurred
+type countEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// sortedCounts turns a map of counts into a list with the largest counts first,
+// keeping at most limit entries when limit is positive
+func sortedCounts(counts map[string]int, limit int) []countEntry {
+	entries := make([]countEntry, 0, len(counts))
+	for name, count := range counts {
+		entries = append(entries, countEntry{name, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
@@ -0,0 +1,341 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// SyncJQL is the query selecting the issues to sync
+var SyncJQL string
+
+// SyncDatabase is the SQLite database file to sync into
+var SyncDatabase string
+
+// SyncFull ignores the last sync and fetches every matching issue
+var SyncFull bool
+
+const syncSchema = `
+CREATE TABLE IF NOT EXISTS issues (
+	key TEXT PRIMARY KEY,
+	id TEXT,
+	project TEXT,
+	type TEXT,
+	summary TEXT,
+	description TEXT,
+	status TEXT,
+	status_category TEXT,
+	priority TEXT,
+	resolution TEXT,
+	assignee TEXT,
+	reporter TEXT,
+	parent_key TEXT,
+	epic_key TEXT,
+	labels TEXT,
+	components TEXT,
+	fix_versions TEXT,
+	created TIMESTAMP,
+	updated TIMESTAMP,
+	resolved TIMESTAMP,
+	fields TEXT
+);
+CREATE TABLE IF NOT EXISTS changelog (
+	issue_key TEXT,
+	history_id TEXT,
+	item INTEGER,
+	author TEXT,
+	created TIMESTAMP,
+	field TEXT,
+	from_value TEXT,
+	to_value TEXT,
+	PRIMARY KEY (history_id, item)
+);
+CREATE TABLE IF NOT EXISTS links (
+	issue_key TEXT,
+	link_id TEXT,
+	type TEXT,
+	direction TEXT,
+	linked_key TEXT,
+	PRIMARY KEY (issue_key, link_id)
+);
+CREATE TABLE IF NOT EXISTS worklogs (
+	id TEXT PRIMARY KEY,
+	issue_key TEXT,
+	author TEXT,
+	started TIMESTAMP,
+	seconds INTEGER,
+	comment TEXT
+);
+CREATE TABLE IF NOT EXISTS comments (
+	id TEXT PRIMARY KEY,
+	issue_key TEXT,
+	author TEXT,
+	created TIMESTAMP,
+	updated TIMESTAMP,
+	body TEXT
+);
+CREATE TABLE IF NOT EXISTS sprints (
+	issue_key TEXT,
+	sprint TEXT,
+	PRIMARY KEY (issue_key, sprint)
+);
+CREATE TABLE IF NOT EXISTS sync_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	jql TEXT,
+	query TEXT,
+	started TIMESTAMP,
+	finished TIMESTAMP,
+	issues INTEGER
+);
+CREATE TABLE IF NOT EXISTS sync_state (
+	jql TEXT PRIMARY KEY,
+	last_updated TIMESTAMP
+);
+`
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Copies issues into a local SQLite database",
+	Long: `Pulls the issues matching a JQL query into a SQLite database for offline
+analysis. Issues, changelog entries, links, worklogs, comments and sprints are
+stored in their own tables.
+
+Each run only fetches issues updated since the last sync of the same query.
+Use --full to fetch everything again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if SyncJQL == "" {
+			log.Fatal("You must include the --jql string parameter")
+		}
+
+		jiraClient, _ := jirasetup.GetJiraClient()
+
+		db, err := sql.Open("sqlite3", SyncDatabase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(syncSchema); err != nil {
+			log.Fatal(err)
+		}
+
+		synced, lastUpdated := syncIssues(jiraClient, db, SyncJQL, SyncFull)
+		fmt.Printf("Synced %d issues into %s", synced, SyncDatabase)
+		if !lastUpdated.IsZero() {
+			fmt.Printf(" (last updated %s)", lastUpdated.Format(time.RFC3339))
+		}
+		fmt.Println()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.PersistentFlags().StringVarP(&SyncJQL, "jql", "q", "", "query selecting the issues to sync")
+	syncCmd.PersistentFlags().StringVar(&SyncDatabase, "db", "jira.db", "SQLite database file")
+	syncCmd.PersistentFlags().BoolVar(&SyncFull, "full", false, "ignore the last sync and fetch every matching issue")
+}
+
+// syncIssues fetches the issues for the query, updated since the last sync unless
+// full is set, and stores them. It returns the number of issues and the new high-water mark.
+func syncIssues(jiraClient *jira.Client, db *sql.DB, userQuery string, full bool) (int, time.Time) {
+	started := time.Now()
+
+	var lastUpdated time.Time
+	if !full {
+		var stored sql.NullTime
+		err := db.QueryRow("SELECT last_updated FROM sync_state WHERE jql = ?", userQuery).Scan(&stored)
+		if err != nil && err != sql.ErrNoRows {
+			log.Fatal(err)
+		}
+		lastUpdated = stored.Time
+	}
+
+	query := jql.New().Raw(userQuery)
+	if !lastUpdated.IsZero() {
+		// JQL reads dates in the profile time zone and only compares to the minute, so
+		// this overlaps the last sync slightly and relies on INSERT OR REPLACE
+		query.Where("updated", ">=", jql.String(jqlTime(lastUpdated.In(getProfileLocation(jiraClient)))))
+	}
+	queryString := query.String()
+
+	issues := searchAllIssues(jiraClient, queryString, &jira.SearchOptions{Expand: "changelog", Fields: []string{"*all"}})
+
+	sprintFieldID := getCustomFieldID(jiraClient, sprintFieldType, "Sprint")
+	epicLinkFieldID := getCustomFieldID(jiraClient, epicLinkFieldType, "Epic Link")
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, issue := range issues {
+		if err := storeIssue(tx, &issue, sprintFieldID, epicLinkFieldID); err != nil {
+			tx.Rollback()
+			log.Fatalf("Couldn't store %s: %s", issue.Key, err)
+		}
+		if updated := time.Time(issue.Fields.Updated); updated.After(lastUpdated) {
+			lastUpdated = updated
+		}
+	}
+
+	if !lastUpdated.IsZero() {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO sync_state (jql, last_updated) VALUES (?, ?)", userQuery, lastUpdated); err != nil {
+			tx.Rollback()
+			log.Fatal(err)
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO sync_runs (jql, query, started, finished, issues) VALUES (?, ?, ?, ?, ?)",
+		userQuery, queryString, started, time.Now(), len(issues)); err != nil {
+		tx.Rollback()
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+
+	return len(issues), lastUpdated
+}
+
+func userName(u *jira.User) string {
+	if u == nil {
+		return ""
+	}
+	return u.DisplayName
+}
+
+func nullTime(t jira.Time) interface{} {
+	if time.Time(t).IsZero() {
+		return nil
+	}
+	return time.Time(t)
+}
+
+func jsonText(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// storeIssue replaces an issue and all of its child rows
+func storeIssue(tx *sql.Tx, issue *jira.Issue, sprintFieldID string, epicLinkFieldID string) error {
+	f := issue.Fields
+
+	for _, table := range []string{"changelog", "links", "worklogs", "comments", "sprints"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE issue_key = ?", issue.Key); err != nil {
+			return err
+		}
+	}
+
+	priority, resolution, parentKey, statusName, statusCategory := "", "", "", "", ""
+	if f.Priority != nil {
+		priority = f.Priority.Name
+	}
+	if f.Resolution != nil {
+		resolution = f.Resolution.Name
+	}
+	if f.Parent != nil {
+		parentKey = f.Parent.Key
+	}
+	if f.Status != nil {
+		statusName = f.Status.Name
+		statusCategory = f.Status.StatusCategory.Name
+	}
+	var components, fixVersions []string
+	for _, component := range f.Components {
+		components = append(components, component.Name)
+	}
+	for _, version := range f.FixVersions {
+		fixVersions = append(fixVersions, version.Name)
+	}
+
+	_, err := tx.Exec(`INSERT OR REPLACE INTO issues (key, id, project, type, summary, description, status, status_category,
+		priority, resolution, assignee, reporter, parent_key, epic_key, labels, components, fix_versions, created, updated, resolved, fields)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		issue.Key, issue.ID, f.Project.Key, f.Type.Name, f.Summary, f.Description, statusName, statusCategory,
+		priority, resolution, userName(f.Assignee), userName(f.Reporter), parentKey, getEpicKey(issue, epicLinkFieldID),
+		jsonText(f.Labels), jsonText(components), jsonText(fixVersions),
+		nullTime(f.Created), nullTime(f.Updated), nullTime(f.Resolutiondate), jsonText(f.Unknowns))
+	if err != nil {
+		return err
+	}
+
+	if issue.Changelog != nil {
+		for _, history := range issue.Changelog.Histories {
+			created, _ := history.CreatedTime()
+			for i, item := range history.Items {
+				if _, err := tx.Exec("INSERT OR REPLACE INTO changelog (issue_key, history_id, item, author, created, field, from_value, to_value) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+					issue.Key, history.Id, i, history.Author.DisplayName, created, item.Field, item.FromString, item.ToString); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, link := range f.IssueLinks {
+		direction, linkedKey := "outward", ""
+		if link.OutwardIssue != nil {
+			linkedKey = link.OutwardIssue.Key
+		} else if link.InwardIssue != nil {
+			direction, linkedKey = "inward", link.InwardIssue.Key
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO links (issue_key, link_id, type, direction, linked_key) VALUES (?, ?, ?, ?, ?)",
+			issue.Key, link.ID, link.Type.Name, direction, linkedKey); err != nil {
+			return err
+		}
+	}
+
+	var worklogs []jira.WorklogRecord
+	if f.Worklog != nil {
+		worklogs = f.Worklog.Worklogs
+	}
+	for _, worklog := range worklogs {
+		var started interface{}
+		if worklog.Started != nil {
+			started = time.Time(*worklog.Started)
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO worklogs (id, issue_key, author, started, seconds, comment) VALUES (?, ?, ?, ?, ?, ?)",
+			worklog.ID, issue.Key, userName(worklog.Author), started, worklog.TimeSpentSeconds, worklog.Comment); err != nil {
+			return err
+		}
+	}
+
+	if f.Comments != nil {
+		for _, comment := range f.Comments.Comments {
+			if _, err := tx.Exec("INSERT OR REPLACE INTO comments (id, issue_key, author, created, updated, body) VALUES (?, ?, ?, ?, ?, ?)",
+				comment.ID, issue.Key, comment.Author.DisplayName, comment.Created, comment.Updated, comment.Body); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sprint := range getSprintNames(issue, sprintFieldID) {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO sprints (issue_key, sprint) VALUES (?, ?)", issue.Key, sprint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
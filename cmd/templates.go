@@ -0,0 +1,91 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// IssueTemplate holds default values for new issues, read from a YAML file
+type IssueTemplate struct {
+	Project     string                 `yaml:"project"`
+	Type        string                 `yaml:"type"`
+	Summary     string                 `yaml:"summary"`
+	Description string                 `yaml:"description"`
+	Assignee    string                 `yaml:"assignee"`
+	Labels      []string               `yaml:"labels"`
+	Components  []string               `yaml:"components"`
+	Parent      string                 `yaml:"parent"`
+	Fields      map[string]interface{} `yaml:"fields"`
+}
+
+// templatesDir is the .jira-tools-templates directory next to the config file
+func templatesDir() string {
+	if config := viper.ConfigFileUsed(); config != "" {
+		return filepath.Join(filepath.Dir(config), ".jira-tools-templates")
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return filepath.Join(home, ".jira-tools-templates")
+}
+
+// loadIssueTemplate reads a template by name from the templates directory, or from a path
+func loadIssueTemplate(name string) IssueTemplate {
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) && filepath.Ext(name) == "" {
+		path = filepath.Join(templatesDir(), name+".yaml")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Couldn't read the %s template: %s", name, err)
+	}
+
+	var template IssueTemplate
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		log.Fatalf("Couldn't read the %s template: %s", name, err)
+	}
+	for field, value := range template.Fields {
+		template.Fields[field] = normalizeYAML(value)
+	}
+	return template
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values the YAML decoder
+// produces into map[string]interface{} so they can be sent as JSON
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+	}
+	return value
+}
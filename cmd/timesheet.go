@@ -0,0 +1,321 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// TimesheetRow is the time one user logged on one issue, per day
+type TimesheetRow struct {
+	User    string
+	Key     string
+	Summary string
+	Seconds []int
+}
+
+// Timesheet is the time logged on each day between two dates
+type Timesheet struct {
+	Days []time.Time
+	Rows []TimesheetRow
+}
+
+// TimesheetUser is the user to report on, defaults to the current user
+var TimesheetUser string
+
+// TimesheetTeam is a comma-separated list of users to report on
+var TimesheetTeam string
+
+// TimesheetFrom is the first day of the timesheet
+var TimesheetFrom string
+
+// TimesheetTo is the last day of the timesheet
+var TimesheetTo string
+
+// TimesheetFormat is the output format: table or csv
+var TimesheetFormat string
+
+// timesheetCmd represents the timesheet command
+var timesheetCmd = &cobra.Command{
+	Use:   "timesheet",
+	Short: "Reports the time logged per issue and day",
+	Long: `Adds up the worklogs of a user, or of a team with --team, between --from and
+--to into a grid with a row per issue, a column per day and totals. The
+default range is the current week up to today.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if TimesheetFormat != "table" && TimesheetFormat != "csv" {
+			log.Fatal("--format must be table or csv")
+		}
+		jiraClient, _ := jirasetup.GetJiraClient()
+		// worklogDate is read in the profile time zone, so days are counted in it too
+		location := getProfileLocation(jiraClient)
+		now := time.Now().In(location)
+
+		from := startOfDay(now)
+		from = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		if TimesheetFrom != "" {
+			from = parseChartDay(TimesheetFrom)
+		}
+		to := startOfDay(now)
+		if TimesheetTo != "" {
+			to = parseChartDay(TimesheetTo)
+		}
+		if to.Before(from) {
+			log.Fatal("--to must not be before --from")
+		}
+
+		names := jql.Split(TimesheetTeam)
+		if len(names) == 0 {
+			names = []string{TimesheetUser}
+		}
+		var users []jira.User
+		for _, name := range names {
+			user, err := lookupUser(jiraClient, name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			users = append(users, *user)
+		}
+
+		timesheet := getTimesheet(jiraClient, users, from, to, location)
+		if TimesheetFormat == "csv" {
+			writeTimesheetCSV(timesheet, len(users) > 1)
+		} else {
+			printTimesheet(timesheet, len(users) > 1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(timesheetCmd)
+
+	timesheetCmd.Flags().StringVarP(&TimesheetUser, "user", "u", "me", "user to report on")
+	timesheetCmd.Flags().StringVarP(&TimesheetTeam, "team", "t", "", "comma-separated list of users to report on")
+	timesheetCmd.Flags().StringVar(&TimesheetFrom, "from", "", "first day, YYYY-MM-DD (default the Monday of this week)")
+	timesheetCmd.Flags().StringVar(&TimesheetTo, "to", "", "last day, YYYY-MM-DD (default today)")
+	timesheetCmd.Flags().StringVar(&TimesheetFormat, "format", "table", "output format: table or csv")
+}
+
+// worklogUserID is how the user is referred to in queries: the account id on Cloud, the username on Server
+func worklogUserID(user jira.User) string {
+	if user.AccountID != "" {
+		return user.AccountID
+	}
+	return user.Name
+}
+
+// getTimesheet searches the issues the users logged time on between from and to, inclusive,
+// and adds up their worklogs per issue and day in location
+func getTimesheet(jiraClient *jira.Client, users []jira.User, from time.Time, to time.Time, location *time.Location) Timesheet {
+	timesheet := Timesheet{}
+	dayIndex := map[string]int{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayIndex[day.Format("2006-01-02")] = len(timesheet.Days)
+		timesheet.Days = append(timesheet.Days, day)
+	}
+
+	userIndex := map[string]int{}
+	var ids []string
+	for i, user := range users {
+		id := worklogUserID(user)
+		userIndex[id] = i
+		ids = append(ids, id)
+	}
+
+	query := jql.New().
+		In("worklogAuthor", ids...).
+		Where("worklogDate", ">=", jql.String(from.Format("2006-01-02"))).
+		Where("worklogDate", "<=", jql.String(to.Format("2006-01-02"))).
+		OrderBy("key", false)
+	issues := searchAllIssues(jiraClient, query.String(), &jira.SearchOptions{Fields: []string{"summary", "worklog"}})
+
+	rowIndex := map[string]int{}
+	for _, issue := range issues {
+		if issue.Fields.Worklog == nil {
+			continue
+		}
+		for _, worklog := range issue.Fields.Worklog.Worklogs {
+			if worklog.Author == nil || worklog.Started == nil {
+				continue
+			}
+			author := worklogUserID(*worklog.Author)
+			if _, ok := userIndex[author]; !ok {
+				continue
+			}
+			day, ok := dayIndex[time.Time(*worklog.Started).In(location).Format("2006-01-02")]
+			if !ok {
+				continue
+			}
+
+			rowKey := author + " " + issue.Key
+			row, ok := rowIndex[rowKey]
+			if !ok {
+				row = len(timesheet.Rows)
+				rowIndex[rowKey] = row
+				timesheet.Rows = append(timesheet.Rows, TimesheetRow{
+					User:    author,
+					Key:     issue.Key,
+					Summary: issue.Fields.Summary,
+					Seconds: make([]int, len(timesheet.Days)),
+				})
+			}
+			timesheet.Rows[row].Seconds[day] += worklog.TimeSpentSeconds
+		}
+	}
+
+	sort.SliceStable(timesheet.Rows, func(i, j int) bool {
+		return userIndex[timesheet.Rows[i].User] < userIndex[timesheet.Rows[j].User]
+	})
+	for i := range timesheet.Rows {
+		timesheet.Rows[i].User = users[userIndex[timesheet.Rows[i].User]].DisplayName
+	}
+	return timesheet
+}
+
+// total adds up seconds per day and overall for the rows that match
+func (t Timesheet) total(match func(TimesheetRow) bool) ([]int, int) {
+	days := make([]int, len(t.Days))
+	total := 0
+	for _, row := range t.Rows {
+		if !match(row) {
+			continue
+		}
+		for i, seconds := range row.Seconds {
+			days[i] += seconds
+			total += seconds
+		}
+	}
+	return days, total
+}
+
+func sumSeconds(seconds []int) int {
+	total := 0
+	for _, s := range seconds {
+		total += s
+	}
+	return total
+}
+
+func printTimesheet(timesheet Timesheet, showUser bool) {
+	if len(timesheet.Rows) == 0 {
+		fmt.Println("No time logged")
+		return
+	}
+	cell := func(seconds int) string {
+		if seconds == 0 {
+			return "-"
+		}
+		return formatSeconds(seconds)
+	}
+	line := func(w *tabwriter.Writer, label string, seconds []int, total int) {
+		fmt.Fprint(w, label)
+		for _, s := range seconds {
+			fmt.Fprintf(w, "\t%s", cell(s))
+		}
+		fmt.Fprintf(w, "\t%s\n", cell(total))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "Issue")
+	for _, day := range timesheet.Days {
+		fmt.Fprintf(w, "\t%s", day.Format("Mon 01-02"))
+	}
+	fmt.Fprintln(w, "\tTotal")
+
+	user := ""
+	for _, row := range timesheet.Rows {
+		if showUser && row.User != user {
+			if user != "" {
+				seconds, total := timesheet.total(func(r TimesheetRow) bool { return r.User == user })
+				line(w, user+" total", seconds, total)
+			}
+			user = row.User
+			// keep the user heading in the same column block as the rows
+			fmt.Fprintln(w, user+strings.Repeat("\t", len(timesheet.Days)+1))
+		}
+		summary := row.Summary
+		if runes := []rune(summary); len(runes) > 40 {
+			summary = string(runes[:37]) + "..."
+		}
+		label := row.Key + " " + summary
+		if showUser {
+			label = "  " + label
+		}
+		line(w, label, row.Seconds, sumSeconds(row.Seconds))
+	}
+	if showUser {
+		seconds, total := timesheet.total(func(r TimesheetRow) bool { return r.User == user })
+		line(w, user+" total", seconds, total)
+	}
+	seconds, total := timesheet.total(func(TimesheetRow) bool { return true })
+	line(w, "Total", seconds, total)
+	w.Flush()
+}
+
+func writeTimesheetCSV(timesheet Timesheet, showUser bool) {
+	hours := func(seconds int) string {
+		return fmt.Sprintf("%.2f", float64(seconds)/3600)
+	}
+	csvWriter := csv.NewWriter(os.Stdout)
+
+	var header []string
+	if showUser {
+		header = append(header, "User")
+	}
+	header = append(header, "Key", "Summary")
+	for _, day := range timesheet.Days {
+		header = append(header, day.Format("2006-01-02"))
+	}
+	csvWriter.Write(append(header, "Total (hours)"))
+
+	for _, row := range timesheet.Rows {
+		var record []string
+		if showUser {
+			record = append(record, row.User)
+		}
+		record = append(record, row.Key, row.Summary)
+		for _, seconds := range row.Seconds {
+			record = append(record, hours(seconds))
+		}
+		csvWriter.Write(append(record, hours(sumSeconds(row.Seconds))))
+	}
+
+	record := []string{"Total", ""}
+	if showUser {
+		record = append(record, "")
+	}
+	seconds, total := timesheet.total(func(TimesheetRow) bool { return true })
+	for _, s := range seconds {
+		record = append(record, hours(s))
+	}
+	csvWriter.Write(append(record, hours(total)))
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// parseAge parses durations like 3d, 2w or 12h. Days and weeks are not
+// understood by time.ParseDuration so they are handled here.
+func parseAge(age string) (time.Duration, error) {
+	age = strings.TrimSpace(age)
+	if age == "" {
+		return 0, nil
+	}
+
+	unit := age[len(age)-1:]
+	switch unit {
+	case "d", "w":
+		count, err := strconv.Atoi(age[:len(age)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", age)
+		}
+		days := count
+		if unit == "w" {
+			days = count * 7
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", age)
+	}
+	return d, nil
+}
+
+// formatAge describes how long ago t was in whole days
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "at an unknown time"
+	}
+	days := int(time.Since(t).Hours() / 24)
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day ago"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
+
+// formatSeconds formats a number of seconds the way Jira shows time spent, e.g. 1h 30m
+func formatSeconds(seconds int) string {
+	if seconds <= 0 {
+		return "0m"
+	}
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// startOfDay returns midnight at the start of the day t falls on
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// previousWorkingDay returns the start of the last weekday before t
+func previousWorkingDay(t time.Time) time.Time {
+	day := startOfDay(t).AddDate(0, 0, -1)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// getProfileLocation returns the time zone of the user's Jira profile, which
+// JQL dates are read in, falling back to the local time zone
+func getProfileLocation(jiraClient *jira.Client) *time.Location {
+	self, _, err := jiraClient.User.GetSelf()
+	if err != nil || self.TimeZone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(self.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// jqlTime formats a time the way JQL date comparisons expect
+func jqlTime(t time.Time) string {
+	return t.Format("2006/01/02 15:04")
+}
+
+// formatDuration formats a duration in days and hours, e.g. 2d 4h
+func formatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	switch {
+	case hours < 1:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case hours < 24:
+		return fmt.Sprintf("%dh", hours)
+	case hours%24 == 0:
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	return fmt.Sprintf("%dd %dh", hours/24, hours%24)
+}
+
+// weekStart returns the Monday starting the week t falls in, in t's time zone
+func weekStart(t time.Time) time.Time {
+	t = startOfDay(t)
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// parseJiraTime parses the timestamps the REST API returns as plain strings
+func parseJiraTime(value string) (time.Time, error) {
+	return time.Parse("2006-01-02T15:04:05.000-0700", value)
+}
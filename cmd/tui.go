@@ -0,0 +1,559 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/patrickjmcd/jira-tools/markup"
+	"github.com/spf13/cobra"
+)
+
+// TUI browses the results of a query in a full-screen terminal interface
+var TUI bool
+
+type browserMode int
+
+const (
+	browseList browserMode = iota
+	browseFilter
+	browseTransition
+	browseAssign
+	browseComment
+)
+
+// issueBrowser is the bubbletea model of the full-screen issue browser
+type issueBrowser struct {
+	jiraClient *jira.Client
+	baseURL    string
+	fetch      func() ([]jira.Issue, error)
+
+	issues  []jira.Issue
+	visible []int
+	cursor  int
+
+	mode             browserMode
+	filter           textinput.Model
+	assignee         textinput.Model
+	comment          textarea.Model
+	transitions      []issueTransition
+	transitionCursor int
+
+	status string
+	busy   bool
+	width  int
+	height int
+}
+
+type issuesLoadedMsg struct {
+	issues []jira.Issue
+	err    error
+}
+
+type transitionsLoadedMsg struct {
+	transitions []issueTransition
+	err         error
+}
+
+type actionDoneMsg struct {
+	message string
+	err     error
+}
+
+var (
+	browserTitle    = lipgloss.NewStyle().Bold(true)
+	browserSelected = lipgloss.NewStyle().Reverse(true)
+	browserDim      = lipgloss.NewStyle().Faint(true)
+	browserError    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	browserPane     = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderLeft(true).PaddingLeft(1)
+)
+
+// addTUIFlag adds --tui to the commands that can show their results in the issue browser
+func addTUIFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&TUI, "tui", false, "browse and triage the results in a full-screen terminal interface")
+}
+
+// browseIssues shows the issues the fetch function returns in the issue browser. The
+// same function is called again to refresh the list after every change, and a failed
+// refresh is shown in the status line.
+func browseIssues(jiraClient *jira.Client, baseURL string, fetch func() ([]jira.Issue, error)) error {
+	// look the deployment up now, so comments don't have to while the screen is in use
+	isCloud(jiraClient)
+	fmt.Println("Loading issues...")
+
+	filter := textinput.New()
+	filter.Prompt = "/"
+	assignee := textinput.New()
+	assignee.Prompt = "Assign to: "
+	assignee.Placeholder = "me, a username, email address or name"
+	comment := textarea.New()
+	comment.Placeholder = "Comment in markdown"
+
+	browser := issueBrowser{
+		jiraClient: jiraClient,
+		baseURL:    baseURL,
+		fetch:      fetch,
+		filter:     filter,
+		assignee:   assignee,
+		comment:    comment,
+	}
+	issues, err := fetch()
+	if err != nil {
+		return err
+	}
+	browser.setIssues(issues)
+	_, err = tea.NewProgram(browser, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (b issueBrowser) Init() tea.Cmd {
+	return nil
+}
+
+func (b *issueBrowser) setIssues(issues []jira.Issue) {
+	b.issues = issues
+	b.applyFilter()
+}
+
+// applyFilter keeps the issues whose key, summary, status, type or assignee contain the filter text
+func (b *issueBrowser) applyFilter() {
+	text := strings.ToLower(strings.TrimSpace(b.filter.Value()))
+	var visible []int
+	for i, issue := range b.issues {
+		if text == "" || strings.Contains(strings.ToLower(issueSearchText(&issue)), text) {
+			visible = append(visible, i)
+		}
+	}
+	b.visible = visible
+	if b.cursor >= len(b.visible) {
+		b.cursor = len(b.visible) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+func issueSearchText(issue *jira.Issue) string {
+	if issue.Fields == nil {
+		return issue.Key
+	}
+	parts := []string{issue.Key, issue.Fields.Summary, issue.Fields.Type.Name, userName(issue.Fields.Assignee)}
+	if issue.Fields.Status != nil {
+		parts = append(parts, issue.Fields.Status.Name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// selected returns the issue under the cursor, or nil when nothing matches the filter
+func (b issueBrowser) selected() *jira.Issue {
+	if len(b.visible) == 0 {
+		return nil
+	}
+	return &b.issues[b.visible[b.cursor]]
+}
+
+func (b issueBrowser) reload() tea.Cmd {
+	return func() tea.Msg {
+		issues, err := b.fetch()
+		return issuesLoadedMsg{issues: issues, err: err}
+	}
+}
+
+func (b issueBrowser) loadTransitions(key string) tea.Cmd {
+	return func() tea.Msg {
+		transitions, err := getTransitions(b.jiraClient, key)
+		return transitionsLoadedMsg{transitions: transitions, err: err}
+	}
+}
+
+func (b issueBrowser) transition(key string, transition issueTransition) tea.Cmd {
+	return func() tea.Msg {
+		result := moveIssue(b.jiraClient, key, transition.Name)
+		return actionDoneMsg{message: fmt.Sprintf("%s moved to %s", key, result.To), err: result.Err}
+	}
+}
+
+func (b issueBrowser) assign(key string, who string) tea.Cmd {
+	return func() tea.Msg {
+		user, err := lookupUser(b.jiraClient, who)
+		if err != nil {
+			return actionDoneMsg{err: err}
+		}
+		assignee := &jira.User{AccountID: user.AccountID, Name: user.Name}
+		if resp, err := b.jiraClient.Issue.UpdateAssignee(key, assignee); err != nil {
+			return actionDoneMsg{err: jira.NewJiraError(resp, err)}
+		}
+		return actionDoneMsg{message: fmt.Sprintf("%s assigned to %s", key, user.DisplayName)}
+	}
+}
+
+func (b issueBrowser) addComment(key string, text string) tea.Cmd {
+	return func() tea.Msg {
+		err := addComment(b.jiraClient, key, text, false)
+		return actionDoneMsg{message: "Commented on " + key, err: err}
+	}
+}
+
+func (b issueBrowser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+		b.comment.SetWidth(b.detailWidth() - 2)
+		b.comment.SetHeight(b.paneHeight() - 2)
+		return b, nil
+	case issuesLoadedMsg:
+		b.busy = false
+		if msg.err != nil {
+			b.status = "Error: " + msg.err.Error()
+			return b, nil
+		}
+		b.setIssues(msg.issues)
+		if b.status == "" {
+			b.status = fmt.Sprintf("Loaded %d issues at %s", len(msg.issues), time.Now().Format("15:04:05"))
+		}
+		return b, nil
+	case transitionsLoadedMsg:
+		b.busy = false
+		if msg.err != nil {
+			b.status = "Error: " + msg.err.Error()
+			return b, nil
+		}
+		if len(msg.transitions) == 0 {
+			b.status = "No transitions are available"
+			return b, nil
+		}
+		b.transitions, b.transitionCursor, b.mode = msg.transitions, 0, browseTransition
+		return b, nil
+	case actionDoneMsg:
+		if msg.err != nil {
+			b.busy = false
+			b.status = "Error: " + msg.err.Error()
+			return b, nil
+		}
+		b.status = msg.message
+		return b, b.reload()
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return b, tea.Quit
+		}
+		switch b.mode {
+		case browseFilter:
+			return b.updateFilter(msg)
+		case browseTransition:
+			return b.updateTransition(msg)
+		case browseAssign:
+			return b.updateAssign(msg)
+		case browseComment:
+			return b.updateComment(msg)
+		}
+		return b.updateList(msg)
+	}
+	return b, nil
+}
+
+func (b issueBrowser) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	issue := b.selected()
+	switch msg.String() {
+	case "q", "esc":
+		if msg.String() == "esc" && b.filter.Value() != "" {
+			b.filter.SetValue("")
+			b.applyFilter()
+			return b, nil
+		}
+		return b, tea.Quit
+	case "up", "k":
+		if b.cursor > 0 {
+			b.cursor--
+		}
+	case "down", "j":
+		if b.cursor < len(b.visible)-1 {
+			b.cursor++
+		}
+	case "pgup":
+		b.cursor -= b.paneHeight()
+		if b.cursor < 0 {
+			b.cursor = 0
+		}
+	case "pgdown":
+		b.cursor += b.paneHeight()
+		if b.cursor > len(b.visible)-1 {
+			b.cursor = len(b.visible) - 1
+		}
+	case "home", "g":
+		b.cursor = 0
+	case "end", "G":
+		b.cursor = len(b.visible) - 1
+	case "/":
+		b.mode = browseFilter
+		return b, b.filter.Focus()
+	case "r":
+		b.busy, b.status = true, ""
+		return b, b.reload()
+	}
+
+	if issue == nil || b.busy {
+		return b, nil
+	}
+	switch msg.String() {
+	case "enter", "o":
+		if err := openBrowser(browseURL(b.baseURL, issue.Key)); err != nil {
+			b.status = "Error: " + err.Error()
+		}
+	case "t":
+		b.busy, b.status = true, "Loading transitions for "+issue.Key
+		return b, b.loadTransitions(issue.Key)
+	case "a":
+		b.mode = browseAssign
+		b.assignee.SetValue("")
+		return b, b.assignee.Focus()
+	case "c":
+		b.mode = browseComment
+		b.comment.SetValue("")
+		return b, b.comment.Focus()
+	}
+	return b, nil
+}
+
+func (b issueBrowser) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		b.filter.SetValue("")
+		fallthrough
+	case "enter":
+		b.filter.Blur()
+		b.mode = browseList
+		b.applyFilter()
+		return b, nil
+	}
+	var cmd tea.Cmd
+	b.filter, cmd = b.filter.Update(msg)
+	b.applyFilter()
+	return b, cmd
+}
+
+func (b issueBrowser) updateTransition(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		b.mode = browseList
+	case "up", "k":
+		if b.transitionCursor > 0 {
+			b.transitionCursor--
+		}
+	case "down", "j":
+		if b.transitionCursor < len(b.transitions)-1 {
+			b.transitionCursor++
+		}
+	case "enter":
+		b.mode = browseList
+		if issue := b.selected(); issue != nil {
+			transition := b.transitions[b.transitionCursor]
+			b.busy, b.status = true, fmt.Sprintf("Moving %s to %s", issue.Key, transition.To.Name)
+			return b, b.transition(issue.Key, transition)
+		}
+	}
+	return b, nil
+}
+
+func (b issueBrowser) updateAssign(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		b.assignee.Blur()
+		b.mode = browseList
+		return b, nil
+	case "enter":
+		b.assignee.Blur()
+		b.mode = browseList
+		who := strings.TrimSpace(b.assignee.Value())
+		if issue := b.selected(); issue != nil && who != "" {
+			b.busy, b.status = true, "Assigning "+issue.Key
+			return b, b.assign(issue.Key, who)
+		}
+		return b, nil
+	}
+	var cmd tea.Cmd
+	b.assignee, cmd = b.assignee.Update(msg)
+	return b, cmd
+}
+
+func (b issueBrowser) updateComment(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		b.comment.Blur()
+		b.mode = browseList
+		return b, nil
+	case "ctrl+s":
+		b.comment.Blur()
+		b.mode = browseList
+		text := b.comment.Value()
+		if issue := b.selected(); issue != nil && strings.TrimSpace(text) != "" {
+			b.busy, b.status = true, "Commenting on "+issue.Key
+			return b, b.addComment(issue.Key, text)
+		}
+		return b, nil
+	}
+	var cmd tea.Cmd
+	b.comment, cmd = b.comment.Update(msg)
+	return b, cmd
+}
+
+func (b issueBrowser) listWidth() int {
+	return b.width * 2 / 5
+}
+
+func (b issueBrowser) detailWidth() int {
+	return b.width - b.listWidth() - 2
+}
+
+// paneHeight is the height left for the list and detail panes after the header and footer
+func (b issueBrowser) paneHeight() int {
+	if b.height < 6 {
+		return 1
+	}
+	return b.height - 4
+}
+
+func (b issueBrowser) View() string {
+	if b.width == 0 {
+		return "Loading..."
+	}
+
+	header := fmt.Sprintf("%d of %d issues", len(b.visible), len(b.issues))
+	if b.filter.Value() != "" && b.mode != browseFilter {
+		header += fmt.Sprintf(" matching %q", b.filter.Value())
+	}
+	panes := lipgloss.JoinHorizontal(lipgloss.Top, b.listView(), browserPane.Render(b.detailView()))
+
+	var footer string
+	switch b.mode {
+	case browseFilter:
+		footer = b.filter.View()
+	case browseAssign:
+		footer = b.assignee.View()
+	case browseComment:
+		footer = browserDim.Render("ctrl+s send • esc cancel")
+	case browseTransition:
+		footer = browserDim.Render("↑/↓ choose • enter move • esc cancel")
+	default:
+		footer = browserDim.Render("↑/↓ move • / filter • enter open • t transition • a assign • c comment • r refresh • q quit")
+	}
+	status := b.status
+	if strings.HasPrefix(status, "Error: ") {
+		status = browserError.Render(status)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, browserTitle.Render(header), panes, footer, status)
+}
+
+func (b issueBrowser) listView() string {
+	width, height := b.listWidth(), b.paneHeight()
+	start := 0
+	if b.cursor >= height {
+		start = b.cursor - height + 1
+	}
+
+	var lines []string
+	for row := start; row < len(b.visible) && row < start+height; row++ {
+		issue := b.issues[b.visible[row]]
+		status := ""
+		if issue.Fields != nil && issue.Fields.Status != nil {
+			status = issue.Fields.Status.Name
+		}
+		line := truncateText(fmt.Sprintf("%-10s %-12s %s", issue.Key, truncateText(status, 12), issueSummary(&issue)), width)
+		if row == b.cursor {
+			if padding := width - lipgloss.Width(line); padding > 0 {
+				line += strings.Repeat(" ", padding)
+			}
+			line = browserSelected.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, browserDim.Render("No issues"))
+	}
+	return lipgloss.NewStyle().Width(width).Height(height).MaxHeight(height).Render(strings.Join(lines, "\n"))
+}
+
+func (b issueBrowser) detailView() string {
+	width, height := b.detailWidth(), b.paneHeight()
+	style := lipgloss.NewStyle().Width(width - 1).Height(height).MaxHeight(height)
+
+	if b.mode == browseComment {
+		return style.Render(b.comment.View())
+	}
+	issue := b.selected()
+	if issue == nil || issue.Fields == nil {
+		return style.Render("")
+	}
+
+	if b.mode == browseTransition {
+		lines := []string{browserTitle.Render("Move " + issue.Key)}
+		for i, transition := range b.transitions {
+			line := fmt.Sprintf("%s → %s", transition.Name, transition.To.Name)
+			if i == b.transitionCursor {
+				line = browserSelected.Render(line)
+			}
+			lines = append(lines, line)
+		}
+		return style.Render(strings.Join(lines, "\n"))
+	}
+
+	fields := issue.Fields
+	lines := []string{
+		browserTitle.Render(issue.Key + " " + fields.Summary),
+		browserDim.Render(browseURL(b.baseURL, issue.Key)),
+		"",
+	}
+	if fields.Status != nil {
+		lines = append(lines, "Status:    "+fields.Status.Name)
+	}
+	lines = append(lines, "Type:      "+fields.Type.Name)
+	if fields.Priority != nil {
+		lines = append(lines, "Priority:  "+fields.Priority.Name)
+	}
+	assignee := userName(fields.Assignee)
+	if assignee == "" {
+		assignee = "Unassigned"
+	}
+	lines = append(lines, "Assignee:  "+assignee)
+	if len(fields.Labels) > 0 {
+		lines = append(lines, "Labels:    "+strings.Join(fields.Labels, ", "))
+	}
+	if updated := issueUpdated(issue); !updated.IsZero() {
+		lines = append(lines, "Updated:   "+formatAge(updated))
+	}
+	if description := markup.WikiToText(fields.Description); description != "" {
+		lines = append(lines, "", description)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// truncateText shortens text to at most width characters, ending it with … when cut
+func truncateText(text string, width int) string {
+	runes := []rune(text)
+	if width <= 0 {
+		return ""
+	}
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -17,22 +17,46 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/fatih/color"
 	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
 	"github.com/spf13/cobra"
 )
 
 //ActionableLinkedIssues holds support issues with resolved or inprogress issues
 type ActionableLinkedIssues struct {
-	Resolved   []jira.Issue
-	InProgress []jira.Issue
+	Resolved   []ActionableIssue
+	InProgress []ActionableIssue
+}
+
+// Issues returns every issue in both lists
+func (a ActionableLinkedIssues) Issues() []jira.Issue {
+	var issues []jira.Issue
+	for _, r := range a.Resolved {
+		issues = append(issues, r.Issue)
+	}
+	for _, p := range a.InProgress {
+		issues = append(issues, p.Issue)
+	}
+	return issues
+}
+
+// ActionableIssue holds an issue along with the last time one of its linked issues changed status
+type ActionableIssue struct {
+	Issue         jira.Issue
+	LinkedChanged time.Time
 }
 
 // Verbose prints out the options
 var Verbose bool
 
+// UnblockedSince limits results to issues whose linked issues changed status within this window
+var UnblockedSince string
+
 //Project is the Jira project name code
 var Project string
 
@@ -60,36 +84,41 @@ are used to create linked issues in other boards`,
 			log.Fatal("Couldn't log on to the Jira server.")
 		}
 
-		actionable := getActionableLinkedIssuesForProject(jiraClient, Project, Verbose)
-		if len(actionable.Resolved) > 0 {
-			color.Red("------------------------------------------------------")
-			color.Red("   The following %d issues have completed linked issues  ", len(actionable.Resolved))
-			color.Red("------------------------------------------------------")
-			for _, issue := range actionable.Resolved {
-				color.Red("[%s] %s - %s/browse/%s", issue.Key, issue.Fields.Summary, url, issue.Key)
+		since, err := parseAge(UnblockedSince)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		statusChanges := statusChangeCache{}
+		getActionable := func() (ActionableLinkedIssues, error) {
+			actionable, err := getActionableLinkedIssuesForProject(jiraClient, Project, Verbose, statusChanges)
+			if err == nil && since > 0 {
+				actionable = filterActionableSince(actionable, time.Now().Add(-since))
 			}
-			color.Red("------------------------------------------------------")
-		} else {
-			color.Green("------------------------------------------------------")
-			color.Green("  No issues have completed linked issues. ")
-			color.Green("------------------------------------------------------")
-		}
-		fmt.Print("\n\n")
-
-		if len(actionable.InProgress) > 0 {
-			color.Yellow("------------------------------------------------------")
-			color.Yellow("   The following %d issues have In Progress linked  ", len(actionable.InProgress))
-			color.Yellow("   issues but are not In Progress ")
-			color.Yellow("------------------------------------------------------")
-			for _, issue := range actionable.InProgress {
-				color.Yellow("[%s] %s - %s/browse/%s", issue.Key, issue.Fields.Summary, url, issue.Key)
+			return actionable, err
+		}
+
+		if TUI {
+			if err := browseIssues(jiraClient, url, func() ([]jira.Issue, error) {
+				actionable, err := getActionable()
+				if err != nil {
+					return nil, err
+				}
+				return actionable.Issues(), nil
+			}); err != nil {
+				log.Fatal(err)
 			}
-			color.Yellow("------------------------------------------------------")
-		} else {
-			color.Green("------------------------------------------------------")
-			color.Green("  No Issues have linked issues In Progress. ")
-			color.Green("------------------------------------------------------")
+			return
 		}
+
+		watchIssues(func() ([]jira.Issue, error) {
+			actionable, err := getActionable()
+			if err != nil {
+				return nil, err
+			}
+			printActionableLinkedIssues(actionable, url)
+			return actionable.Issues(), nil
+		})
 	},
 }
 
@@ -104,12 +133,47 @@ func init() {
 	unblockedCmd.PersistentFlags().StringVarP(&Project, "project", "p", "", "Jira project to use")
 	unblockedCmd.MarkFlagRequired("project")
 	unblockedCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
+	unblockedCmd.PersistentFlags().StringVar(&UnblockedSince, "since", "", "only show issues whose linked issues changed status within this window (e.g. 3d, 12h)")
+	addWatchFlags(unblockedCmd)
+	addTUIFlag(unblockedCmd)
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// unblockedCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+func printActionableLinkedIssues(actionable ActionableLinkedIssues, url string) {
+	if len(actionable.Resolved) > 0 {
+		color.Red("------------------------------------------------------")
+		color.Red("   The following %d issues have completed linked issues  ", len(actionable.Resolved))
+		color.Red("------------------------------------------------------")
+		for _, a := range actionable.Resolved {
+			color.Red("[%s] %s - %s (blocker resolved %s)", a.Issue.Key, a.Issue.Fields.Summary, browseURL(url, a.Issue.Key), formatAge(a.LinkedChanged))
+		}
+		color.Red("------------------------------------------------------")
+	} else {
+		color.Green("------------------------------------------------------")
+		color.Green("  No issues have completed linked issues. ")
+		color.Green("------------------------------------------------------")
+	}
+	fmt.Print("\n\n")
+
+	if len(actionable.InProgress) > 0 {
+		color.Yellow("------------------------------------------------------")
+		color.Yellow("   The following %d issues have In Progress linked  ", len(actionable.InProgress))
+		color.Yellow("   issues but are not In Progress ")
+		color.Yellow("------------------------------------------------------")
+		for _, a := range actionable.InProgress {
+			color.Yellow("[%s] %s - %s (blocker started %s)", a.Issue.Key, a.Issue.Fields.Summary, browseURL(url, a.Issue.Key), formatAge(a.LinkedChanged))
+		}
+		color.Yellow("------------------------------------------------------")
+	} else {
+		color.Green("------------------------------------------------------")
+		color.Green("  No Issues have linked issues In Progress. ")
+		color.Green("------------------------------------------------------")
+	}
+}
+
 func getLinkedIssuesForIssue(jiraClient *jira.Client, issue *jira.Issue) []*jira.Issue {
 	issueLinks := issue.Fields.IssueLinks
 	var linkedIssues []*jira.Issue
@@ -124,19 +188,14 @@ func getLinkedIssuesForIssue(jiraClient *jira.Client, issue *jira.Issue) []*jira
 	return linkedIssues
 }
 
-func getActionableLinkedIssuesForProject(jiraClient *jira.Client, projectName string, verbose bool) ActionableLinkedIssues {
+func getActionableLinkedIssuesForProject(jiraClient *jira.Client, projectName string, verbose bool, statusChanges statusChangeCache) (ActionableLinkedIssues, error) {
+	var issuesWithResolvedLinkedIssues []ActionableIssue
+	var issuesWithInProgressLinkedIssues []ActionableIssue
 
-	searchOpts := jira.SearchOptions{
-		MaxResults: 999,
-	}
-
-	var issuesWithResolvedLinkedIssues []jira.Issue
-	var issuesWithInProgressLinkedIssues []jira.Issue
-
-	projectIssues, _, pErr := jiraClient.Issue.Search("project="+projectName+" and resolved is EMPTY", &searchOpts)
-
-	if pErr != nil {
-		log.Fatal(pErr)
+	query := jql.New().Eq("project", projectName).IsEmpty("resolved").String()
+	projectIssues, err := searchIssues(jiraClient, query, nil)
+	if err != nil {
+		return ActionableLinkedIssues{}, err
 	}
 
 	for _, issue := range projectIssues {
@@ -167,12 +226,26 @@ func getActionableLinkedIssuesForProject(jiraClient *jira.Client, projectName st
 				linkedIssuesInProgress = true
 			}
 		}
-		if !linkedIssuesStillPending && len(linkedIssues) > 0 {
-			issuesWithResolvedLinkedIssues = append(issuesWithResolvedLinkedIssues, issue)
+		resolved := !linkedIssuesStillPending && len(linkedIssues) > 0
+		inProgress := linkedIssuesInProgress && len(linkedIssues) > 0 && issue.Fields.Status.Name != "In Progress" && issue.Fields.Status.Name != "Work in progress"
+		if !resolved && !inProgress {
+			continue
 		}
-
-		if linkedIssuesInProgress && len(linkedIssues) > 0 && issue.Fields.Status.Name != "In Progress" && issue.Fields.Status.Name != "Work in progress" {
-			issuesWithInProgressLinkedIssues = append(issuesWithInProgressLinkedIssues, issue)
+		changed, err := statusChanges.latest(jiraClient, linkedIssues)
+		if err != nil {
+			return ActionableLinkedIssues{}, err
+		}
+		if resolved {
+			issuesWithResolvedLinkedIssues = append(issuesWithResolvedLinkedIssues, ActionableIssue{
+				Issue:         issue,
+				LinkedChanged: changed,
+			})
+		}
+		if inProgress {
+			issuesWithInProgressLinkedIssues = append(issuesWithInProgressLinkedIssues, ActionableIssue{
+				Issue:         issue,
+				LinkedChanged: changed,
+			})
 		}
 
 	}
@@ -182,8 +255,87 @@ func getActionableLinkedIssuesForProject(jiraClient *jira.Client, projectName st
 		fmt.Println()
 	}
 
+	// oldest changes first so the stalest issues are at the top of the list
+	sortActionableIssues(issuesWithResolvedLinkedIssues)
+	sortActionableIssues(issuesWithInProgressLinkedIssues)
+
 	return ActionableLinkedIssues{
 		issuesWithResolvedLinkedIssues,
 		issuesWithInProgressLinkedIssues,
+	}, nil
+}
+
+// getStatusChangeTime fetches the changelog of an issue and returns the last time its status changed
+func getStatusChangeTime(jiraClient *jira.Client, issueKey string) (time.Time, error) {
+	var lastChange time.Time
+
+	histories, err := getIssueChangelog(jiraClient, issueKey)
+	if err != nil {
+		return lastChange, err
+	}
+
+	for _, history := range histories {
+		for _, item := range history.Items {
+			if item.Field != "status" {
+				continue
+			}
+			created, err := history.CreatedTime()
+			if err == nil && created.After(lastChange) {
+				lastChange = created
+			}
+		}
+	}
+	return lastChange, nil
+}
+
+// statusChangeCache holds the last status change of each linked issue, keyed by the
+// issue key and its current status, so blockers shared by several issues or seen
+// again on the next --watch poll don't have their changelog fetched again
+type statusChangeCache map[string]time.Time
+
+// latest returns the most recent status change across all linked issues,
+// which is when the last of them was resolved or started
+func (c statusChangeCache) latest(jiraClient *jira.Client, linkedIssues []*jira.Issue) (time.Time, error) {
+	var latest time.Time
+	for _, lIssue := range linkedIssues {
+		cacheKey := lIssue.Key + "|" + lIssue.Fields.Status.Name
+		changed, ok := c[cacheKey]
+		if !ok {
+			var err error
+			changed, err = getStatusChangeTime(jiraClient, lIssue.Key)
+			if err != nil {
+				return latest, err
+			}
+			c[cacheKey] = changed
+		}
+		if changed.After(latest) {
+			latest = changed
+		}
+	}
+	return latest, nil
+}
+
+func sortActionableIssues(issues []ActionableIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		// issues without any status history go last
+		if issues[i].LinkedChanged.IsZero() != issues[j].LinkedChanged.IsZero() {
+			return !issues[i].LinkedChanged.IsZero()
+		}
+		return issues[i].LinkedChanged.Before(issues[j].LinkedChanged)
+	})
+}
+
+func filterActionableSince(actionable ActionableLinkedIssues, since time.Time) ActionableLinkedIssues {
+	var filtered ActionableLinkedIssues
+	for _, a := range actionable.Resolved {
+		if a.LinkedChanged.After(since) {
+			filtered.Resolved = append(filtered.Resolved, a)
+		}
+	}
+	for _, a := range actionable.InProgress {
+		if a.LinkedChanged.After(since) {
+			filtered.InProgress = append(filtered.InProgress, a)
+		}
 	}
+	return filtered
 }
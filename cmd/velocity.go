@@ -0,0 +1,229 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/patrickjmcd/jira-tools/jql"
+	"github.com/spf13/cobra"
+)
+
+// SprintVelocity is the committed and completed story points of a closed sprint
+type SprintVelocity struct {
+	Name      string
+	Committed float64
+	Completed float64
+}
+
+// VelocityBoard is the name or id of the board to measure
+var VelocityBoard string
+
+// VelocitySprints is how many closed sprints to measure
+var VelocitySprints int
+
+// VelocityVersion is a fixVersion to forecast
+var VelocityVersion string
+
+// VelocityEpic is an epic to forecast
+var VelocityEpic string
+
+// VelocitySimulations is the number of Monte Carlo runs in the forecast
+var VelocitySimulations int
+
+// velocityCmd represents the velocity command
+var velocityCmd = &cobra.Command{
+	Use:   "velocity",
+	Short: "Velocity of the last closed sprints and a Monte Carlo forecast",
+	Long: `Shows the committed and completed story points of a board's last closed
+sprints with their average and standard deviation.
+
+With --version or --epic it also forecasts how many more sprints the remaining
+work needs, by repeatedly drawing completed points from the measured sprints
+at random until the backlog is used up.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if VelocityBoard == "" {
+			log.Fatal("You must specify a board with the -b or --board string flag")
+		}
+		if VelocitySprints < 1 {
+			log.Fatal("--sprints must be at least 1")
+		}
+		if VelocitySimulations < 1 {
+			log.Fatal("--simulations must be at least 1")
+		}
+		jiraClient, url := jirasetup.GetJiraClient()
+		board := getBoard(jiraClient, VelocityBoard)
+		velocities := getVelocities(jiraClient, board, url)
+		printVelocities(velocities)
+
+		if VelocityVersion == "" && VelocityEpic == "" {
+			return
+		}
+		remaining := getRemainingPoints(jiraClient)
+		fmt.Printf("\nRemaining work: %g points\n", remaining)
+		if remaining <= 0 {
+			return
+		}
+		var completed []float64
+		for _, velocity := range velocities {
+			completed = append(completed, velocity.Completed)
+		}
+		forecast := forecastSprints(completed, remaining, VelocitySimulations, rand.New(rand.NewSource(time.Now().UnixNano())))
+		if forecast == nil {
+			log.Fatal("The measured sprints completed no points, so there is nothing to forecast with")
+		}
+		fmt.Printf("Sprints to finish: 50%% chance within %d, 85%% within %d, 95%% within %d\n",
+			intPercentile(forecast, 50), intPercentile(forecast, 85), intPercentile(forecast, 95))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(velocityCmd)
+
+	velocityCmd.PersistentFlags().StringVarP(&VelocityBoard, "board", "b", "", "name or id of the board")
+	velocityCmd.PersistentFlags().IntVarP(&VelocitySprints, "sprints", "n", 6, "number of closed sprints to measure")
+	velocityCmd.PersistentFlags().StringVar(&VelocityVersion, "version", "", "forecast the unfinished work in this fixVersion")
+	velocityCmd.PersistentFlags().StringVar(&VelocityEpic, "epic", "", "forecast the unfinished work in this epic")
+	velocityCmd.PersistentFlags().IntVar(&VelocitySimulations, "simulations", 10000, "number of Monte Carlo runs in the forecast")
+}
+
+// getVelocities measures the board's last closed sprints, oldest first
+func getVelocities(jiraClient *jira.Client, board jira.Board, baseURL string) []SprintVelocity {
+	sprints := getBoardSprints(jiraClient, board.ID, "closed")
+	if len(sprints) == 0 {
+		log.Fatalf("%s has no closed sprints", board.Name)
+	}
+	sort.SliceStable(sprints, func(i, j int) bool {
+		return sprintTime(sprints[i].CompleteDate).Before(sprintTime(sprints[j].CompleteDate))
+	})
+	if len(sprints) > VelocitySprints {
+		sprints = sprints[len(sprints)-VelocitySprints:]
+	}
+
+	var velocities []SprintVelocity
+	for _, sprint := range sprints {
+		data := getSprintData(jiraClient, board, sprint, baseURL)
+		velocities = append(velocities, SprintVelocity{
+			Name:      sprint.Name,
+			Committed: sumPoints(data.CommittedIssues),
+			Completed: sumPoints(data.CompletedIssues),
+		})
+	}
+	return velocities
+}
+
+// meanAndStdDev returns the mean and population standard deviation of the values
+func meanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+func printVelocities(velocities []SprintVelocity) {
+	var committed, completed []float64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Sprint\tCommitted\tCompleted")
+	for _, velocity := range velocities {
+		fmt.Fprintf(w, "%s\t%g\t%g\n", velocity.Name, velocity.Committed, velocity.Completed)
+		committed = append(committed, velocity.Committed)
+		completed = append(completed, velocity.Completed)
+	}
+	committedMean, committedStdDev := meanAndStdDev(committed)
+	completedMean, completedStdDev := meanAndStdDev(completed)
+	fmt.Fprintf(w, "Average\t%.1f\t%.1f\n", committedMean, completedMean)
+	fmt.Fprintf(w, "Standard deviation\t%.1f\t%.1f\n", committedStdDev, completedStdDev)
+	w.Flush()
+}
+
+// getRemainingPoints sums the story points of the unresolved issues in --version or --epic
+func getRemainingPoints(jiraClient *jira.Client) float64 {
+	pointsFieldID := getStoryPointsFieldID(jiraClient)
+	if pointsFieldID == "" {
+		log.Fatal("Couldn't find a story points field")
+	}
+
+	query := jql.New().IsEmpty("resolution")
+	if VelocityVersion != "" {
+		query.Eq("fixVersion", VelocityVersion)
+	}
+	if VelocityEpic != "" {
+		epic := []*jql.Query{jql.New().Eq("parent", VelocityEpic)}
+		if epicLinkFieldID := getCustomFieldID(jiraClient, epicLinkFieldType, "Epic Link"); epicLinkFieldID != "" {
+			epic = append(epic, jql.New().Eq(customFieldClause(epicLinkFieldID), VelocityEpic))
+		}
+		query.Any(epic...)
+	}
+
+	var remaining float64
+	for _, issue := range searchAllIssues(jiraClient, query.String(), &jira.SearchOptions{Fields: []string{pointsFieldID}}) {
+		remaining += getStoryPoints(&issue, pointsFieldID)
+	}
+	return remaining
+}
+
+// forecastSprints simulates finishing the remaining points by drawing a past
+// velocity at random for each sprint, and returns the sprint count of each run.
+// It returns nil when no velocity is positive, as the backlog would never finish.
+func forecastSprints(velocities []float64, remaining float64, runs int, random *rand.Rand) []int {
+	positive := false
+	for _, velocity := range velocities {
+		positive = positive || velocity > 0
+	}
+	if !positive || runs < 1 {
+		return nil
+	}
+
+	results := make([]int, runs)
+	for run := range results {
+		left := remaining
+		for left > 0 {
+			left -= velocities[random.Intn(len(velocities))]
+			results[run]++
+		}
+	}
+	return results
+}
+
+// intPercentile returns the pth percentile (0-100) of the values using the nearest-rank method
+func intPercentile(values []int, p float64) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Ints(values)
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
@@ -0,0 +1,219 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// WatchInterval re-runs the query on this interval when set
+var WatchInterval string
+
+// WatchNotify sends a desktop notification through notify-send when results change
+var WatchNotify bool
+
+// WatchHook is a shell command run when results change
+var WatchHook string
+
+// SnapshotDiff holds the issues that changed between two runs of a query
+type SnapshotDiff struct {
+	Added   []jira.Issue
+	Removed []jira.Issue
+	Changed []jira.Issue
+}
+
+// Empty reports whether nothing changed between the two runs
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// addWatchFlags adds --watch, --notify and --on-change to a command that runs its query through watchIssues
+func addWatchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&WatchInterval, "watch", "", "re-run the query on this interval and highlight changes (e.g. 30s, 5m)")
+	cmd.Flags().BoolVar(&WatchNotify, "notify", false, "send a desktop notification with notify-send when watched results change")
+	cmd.Flags().StringVar(&WatchHook, "on-change", "", "shell command to run when watched results change")
+}
+
+// watchIssues runs the query once, or repeatedly if --watch was given. The query
+// prints its own results and returns the issues it printed so they can be compared
+// with the previous run. While watching, a failed run is reported and retried on the
+// next interval.
+func watchIssues(query func() ([]jira.Issue, error)) {
+	if WatchInterval == "" {
+		if _, err := query(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	interval, err := parseAge(WatchInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if interval <= 0 {
+		log.Fatal("The --watch interval must be greater than zero")
+	}
+
+	var previous map[string]jira.Issue
+	for {
+		fmt.Printf("\n===== %s =====\n\n", time.Now().Format("2006-01-02 15:04:05"))
+		issues, err := query()
+		if err != nil {
+			color.Red("The query failed, trying again in %s: %s", WatchInterval, err)
+			time.Sleep(interval)
+			continue
+		}
+		current := snapshotIssues(issues)
+
+		if previous != nil {
+			diff := diffSnapshots(previous, current)
+			printSnapshotDiff(diff)
+			if !diff.Empty() {
+				notifyChanges(diff)
+			}
+		}
+		previous = current
+
+		time.Sleep(interval)
+	}
+}
+
+func snapshotIssues(issues []jira.Issue) map[string]jira.Issue {
+	snapshot := make(map[string]jira.Issue, len(issues))
+	for _, issue := range issues {
+		snapshot[issue.Key] = issue
+	}
+	return snapshot
+}
+
+func diffSnapshots(previous map[string]jira.Issue, current map[string]jira.Issue) SnapshotDiff {
+	var diff SnapshotDiff
+	for key, issue := range current {
+		old, ok := previous[key]
+		if !ok {
+			diff.Added = append(diff.Added, issue)
+			continue
+		}
+		if issueUpdated(&old) != issueUpdated(&issue) {
+			diff.Changed = append(diff.Changed, issue)
+		}
+	}
+	for key, issue := range previous {
+		if _, ok := current[key]; !ok {
+			diff.Removed = append(diff.Removed, issue)
+		}
+	}
+	// maps iterate in random order, keep the output stable between runs
+	for _, issues := range [][]jira.Issue{diff.Added, diff.Removed, diff.Changed} {
+		sortIssuesByKey(issues)
+	}
+	return diff
+}
+
+// sortIssuesByKey orders issues by project and then by issue number
+func sortIssuesByKey(issues []jira.Issue) {
+	sort.Slice(issues, func(i, j int) bool {
+		pi, ni := splitIssueKey(issues[i].Key)
+		pj, nj := splitIssueKey(issues[j].Key)
+		if pi != pj {
+			return pi < pj
+		}
+		return ni < nj
+	})
+}
+
+func splitIssueKey(key string) (string, int) {
+	dash := strings.LastIndex(key, "-")
+	if dash < 0 {
+		return key, 0
+	}
+	number, _ := strconv.Atoi(key[dash+1:])
+	return key[:dash], number
+}
+
+func issueUpdated(i *jira.Issue) time.Time {
+	if i.Fields == nil {
+		return time.Time{}
+	}
+	return time.Time(i.Fields.Updated)
+}
+
+func printSnapshotDiff(diff SnapshotDiff) {
+	fmt.Println()
+	if diff.Empty() {
+		fmt.Println("No changes since the last run")
+		return
+	}
+	for _, issue := range diff.Added {
+		color.Green("+ [%s] %s", issue.Key, issueSummary(&issue))
+	}
+	for _, issue := range diff.Removed {
+		color.Red("- [%s] %s", issue.Key, issueSummary(&issue))
+	}
+	for _, issue := range diff.Changed {
+		color.Yellow("~ [%s] %s", issue.Key, issueSummary(&issue))
+	}
+}
+
+func issueSummary(i *jira.Issue) string {
+	if i.Fields == nil {
+		return ""
+	}
+	return i.Fields.Summary
+}
+
+func diffKeys(issues []jira.Issue) string {
+	keys := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		keys = append(keys, issue.Key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func notifyChanges(diff SnapshotDiff) {
+	message := fmt.Sprintf("%d new, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+
+	if WatchNotify {
+		if err := exec.Command("notify-send", "jira-tools", message).Run(); err != nil {
+			fmt.Println("Couldn't send desktop notification:", err)
+		}
+	}
+
+	if WatchHook != "" {
+		hook := exec.Command("sh", "-c", WatchHook)
+		hook.Env = append(os.Environ(),
+			"JIRA_TOOLS_MESSAGE="+message,
+			"JIRA_TOOLS_ADDED="+diffKeys(diff.Added),
+			"JIRA_TOOLS_REMOVED="+diffKeys(diff.Removed),
+			"JIRA_TOOLS_CHANGED="+diffKeys(diff.Changed),
+		)
+		hook.Stdout = os.Stdout
+		hook.Stderr = os.Stderr
+		if err := hook.Run(); err != nil {
+			fmt.Println("Change hook failed:", err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2018 Patrick McDonagh <patrickjmcd@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+	jirasetup "github.com/patrickjmcd/jira-tools/jirasetup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	worklogDurationPattern = regexp.MustCompile(`^(\d+w)?(\d+d)?(\d+h)?(\d+m)?$`)
+	clockPattern           = regexp.MustCompile(`^\d{1,2}:\d{2}$`)
+)
+
+// WorklogStarted is when the work started, defaults to now
+var WorklogStarted string
+
+// WorklogComment describes the work that was done
+var WorklogComment string
+
+// worklogCmd represents the worklog command
+var worklogCmd = &cobra.Command{
+	Use:   "worklog",
+	Short: "Log time spent on issues",
+}
+
+// worklogAddCmd represents the worklog add command
+var worklogAddCmd = &cobra.Command{
+	Use:   "add KEY DURATION",
+	Short: "Logs time spent on an issue",
+	Long: `Logs time spent on an issue. The duration uses Jira's units, e.g. 45m, 1h30m
+or "1d 4h"; how long a day or week is depends on the Jira time tracking settings.
+
+--started takes "now", "today", "yesterday", a weekday name for the most recent such
+day, or a YYYY-MM-DD date, optionally followed by a HH:MM time:
+
+  jira-tools worklog add ABC-123 1h30m --started "yesterday 14:00" --comment "Code review"
+
+A day without a time means 09:00.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := strings.ToUpper(args[0])
+		timeSpent, err := parseWorklogDuration(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		started, err := parseWorklogTime(WorklogStarted, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		jiraClient, _ := jirasetup.GetJiraClient()
+		startedTime := jira.Time(started)
+		record := &jira.WorklogRecord{
+			Started:   &startedTime,
+			TimeSpent: timeSpent,
+			Comment:   readTextArg(WorklogComment),
+		}
+		added, resp, err := jiraClient.Issue.AddWorklogRecord(key, record)
+		if err != nil {
+			log.Fatal(jira.NewJiraError(resp, err))
+		}
+		fmt.Printf("Logged %s on %s, started %s\n", formatSeconds(added.TimeSpentSeconds), key, started.Format("Mon 2006-01-02 15:04"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(worklogCmd)
+	worklogCmd.AddCommand(worklogAddCmd)
+
+	worklogAddCmd.Flags().StringVarP(&WorklogStarted, "started", "s", "now", "when the work started, e.g. \"yesterday 14:00\" or \"2024-05-01 09:30\"")
+	worklogAddCmd.Flags().StringVarP(&WorklogComment, "comment", "m", "", "description of the work, @file to read it from a file or - for stdin")
+}
+
+// parseWorklogDuration checks a duration like 1h30m and spaces it out the way Jira
+// expects, e.g. 1h 30m
+func parseWorklogDuration(duration string) (string, error) {
+	compact := strings.ToLower(strings.Join(strings.Fields(duration), ""))
+	match := worklogDurationPattern.FindStringSubmatch(compact)
+	if compact == "" || match == nil {
+		return "", fmt.Errorf("invalid duration %q, use units of w, d, h and m such as 1h30m", duration)
+	}
+	var parts []string
+	for _, part := range match[1:] {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// parseWorklogTime parses a day, optionally followed by a HH:MM time, relative to now
+func parseWorklogTime(value string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 || (len(fields) == 1 && fields[0] == "now") {
+		return now, nil
+	}
+
+	day := startOfDay(now)
+	clock := "09:00"
+	if clockPattern.MatchString(fields[len(fields)-1]) {
+		clock = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	switch len(fields) {
+	case 0:
+	case 1:
+		parsed, err := parseWorklogDay(fields[0], day)
+		if err != nil {
+			return time.Time{}, err
+		}
+		day = parsed
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day %q", clock)
+	}
+	return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
+
+// parseWorklogDay parses today, yesterday, a weekday name or a YYYY-MM-DD date
+func parseWorklogDay(value string, today time.Time) (time.Time, error) {
+	switch value {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	for offset := 0; offset < 7; offset++ {
+		day := today.AddDate(0, 0, -offset)
+		name := strings.ToLower(day.Weekday().String())
+		if value == name || value == name[:3] {
+			return day, nil
+		}
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, today.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q, use today, yesterday, a weekday or YYYY-MM-DD", value)
+	}
+	return day, nil
+}
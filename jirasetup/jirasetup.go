@@ -8,6 +8,7 @@ import (
 	"strings"
 	"syscall"
 
+	jira "github.com/andygrunwald/go-jira"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -68,3 +69,17 @@ func GetEnvVariablesOrAsk() (string, string, string) {
 
 	return jiraURL, jiraUsername, jiraAPIKey
 }
+
+// GetJiraClient -- logs on to the Jira server and returns the client along with the server url
+func GetJiraClient() (*jira.Client, string) {
+	url, username, apiKey := GetEnvVariablesOrAsk()
+	transport := jira.BasicAuthTransport{
+		Username: username,
+		Password: apiKey,
+	}
+	jiraClient, err := jira.NewClient(transport.Client(), url)
+	if err != nil {
+		log.Fatal("Couldn't log on to the Jira server.")
+	}
+	return jiraClient, url
+}
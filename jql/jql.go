@@ -0,0 +1,258 @@
+package jql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Value is the right hand side of a clause: a quoted literal, a function call or a keyword
+type Value struct {
+	jql string
+}
+
+// String returns a quoted literal value
+func String(value string) Value {
+	return Value{Quote(value)}
+}
+
+// Number returns an unquoted number, used for ids such as sprint = 42 that would
+// be treated as names if quoted
+func Number(value int) Value {
+	return Value{strconv.Itoa(value)}
+}
+
+// Func returns a function call such as currentUser() or startOfDay("-7d"). It
+// returns an error if the function name is not a valid identifier.
+func Func(name string, args ...string) (Value, error) {
+	if !functionName.MatchString(name) {
+		return Value{}, fmt.Errorf("jql: invalid function name %q", name)
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return Value{name + "(" + strings.Join(quoted, ", ") + ")"}, nil
+}
+
+// MustFunc is Func for function names known to be valid, such as currentUser. It
+// panics if the name is invalid.
+func MustFunc(name string, args ...string) Value {
+	value, err := Func(name, args...)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// String renders the value as it appears in a query
+func (v Value) String() string {
+	return v.jql
+}
+
+// Empty is the EMPTY keyword used with is and is not
+var Empty = Value{"EMPTY"}
+
+// List returns a parenthesised list of quoted literals for use with in and not in
+func List(values ...string) Value {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = Quote(value)
+	}
+	return Value{"(" + strings.Join(quoted, ", ") + ")"}
+}
+
+// Query builds a JQL query out of clauses joined with AND and an optional ORDER BY
+type Query struct {
+	clauses []string
+	orderBy []string
+}
+
+// New returns an empty query
+func New() *Query {
+	return &Query{}
+}
+
+// Where adds a clause comparing a field with a value. It panics on unknown operators,
+// which are always a programming error.
+func (q *Query) Where(field string, operator string, value Value) *Query {
+	operator = strings.ToLower(strings.TrimSpace(operator))
+	if !operators[operator] {
+		panic(fmt.Sprintf("jql: unknown operator %q", operator))
+	}
+	q.clauses = append(q.clauses, Field(field)+" "+operator+" "+value.jql)
+	return q
+}
+
+// Eq adds a field = "value" clause
+func (q *Query) Eq(field string, value string) *Query {
+	return q.Where(field, "=", String(value))
+}
+
+// In adds a field in ("a", "b") clause. Empty value lists are ignored so optional
+// filters can be added unconditionally.
+func (q *Query) In(field string, values ...string) *Query {
+	if len(values) == 0 {
+		return q
+	}
+	return q.Where(field, "in", List(values...))
+}
+
+// NotIn adds a field not in ("a", "b") clause. Empty value lists are ignored.
+func (q *Query) NotIn(field string, values ...string) *Query {
+	if len(values) == 0 {
+		return q
+	}
+	return q.Where(field, "not in", List(values...))
+}
+
+// IsEmpty adds a field is EMPTY clause
+func (q *Query) IsEmpty(field string) *Query {
+	return q.Where(field, "is", Empty)
+}
+
+// Any adds a clause that matches when any of the sub-queries match. The
+// ORDER BY of the sub-queries is ignored.
+func (q *Query) Any(queries ...*Query) *Query {
+	var alternatives []string
+	for _, sub := range queries {
+		switch len(sub.clauses) {
+		case 0:
+		case 1:
+			alternatives = append(alternatives, sub.clauses[0])
+		default:
+			alternatives = append(alternatives, "("+strings.Join(sub.clauses, " AND ")+")")
+		}
+	}
+	if len(alternatives) > 0 {
+		q.clauses = append(q.clauses, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	return q
+}
+
+// Raw adds a trusted JQL fragment, such as a user supplied query or a saved filter.
+// Any ORDER BY in the fragment is kept and moved to the end of the query.
+func (q *Query) Raw(fragment string) *Query {
+	where, orderBy := splitOrderBy(fragment)
+	if where != "" {
+		q.clauses = append(q.clauses, "("+where+")")
+	}
+	if orderBy != "" {
+		q.orderBy = append(q.orderBy, orderBy)
+	}
+	return q
+}
+
+// OrderBy adds a sort field
+func (q *Query) OrderBy(field string, descending bool) *Query {
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	q.orderBy = append(q.orderBy, Field(field)+" "+direction)
+	return q
+}
+
+// SortBy adds sort fields from a comma-separated list such as "priority,-updated",
+// where a leading - sorts that field in descending order
+func (q *Query) SortBy(list string) *Query {
+	for _, field := range Split(list) {
+		descending := strings.HasPrefix(field, "-")
+		field = strings.TrimSpace(strings.TrimPrefix(field, "-"))
+		if field != "" {
+			q.OrderBy(field, descending)
+		}
+	}
+	return q
+}
+
+// Clone returns a copy of the query that can be extended independently
+func (q *Query) Clone() *Query {
+	return &Query{
+		clauses: append([]string(nil), q.clauses...),
+		orderBy: append([]string(nil), q.orderBy...),
+	}
+}
+
+// String renders the query
+func (q *Query) String() string {
+	query := strings.Join(q.clauses, " AND ")
+	if len(q.orderBy) > 0 {
+		if query != "" {
+			query += " "
+		}
+		query += "ORDER BY " + strings.Join(q.orderBy, ", ")
+	}
+	return query
+}
+
+// Quote returns value as a JQL string literal with quotes and backslashes escaped
+func Quote(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + escaped + `"`
+}
+
+// Field returns a field name that is safe to use in a query. Simple names and
+// custom field references such as cf[10010] are left alone, anything else is quoted.
+func Field(name string) string {
+	name = strings.TrimSpace(name)
+	if fieldName.MatchString(name) || customFieldName.MatchString(name) {
+		return name
+	}
+	return Quote(name)
+}
+
+// Split turns a comma-separated flag value into trimmed, non-empty values
+func Split(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+var (
+	fieldName       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]*$`)
+	customFieldName = regexp.MustCompile(`^cf\[[0-9]+\]$`)
+	functionName    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	orderByKeyword  = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+	operators = map[string]bool{
+		"=": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true,
+		"~": true, "!~": true, "in": true, "not in": true, "is": true, "is not": true,
+		"was": true, "was in": true, "was not": true, "was not in": true, "changed": true,
+	}
+)
+
+// splitOrderBy separates the ORDER BY part of a query, ignoring any that appear inside quotes
+func splitOrderBy(query string) (string, string) {
+	for _, loc := range orderByKeyword.FindAllStringIndex(query, -1) {
+		if insideQuotes(query[:loc[0]]) {
+			continue
+		}
+		return strings.TrimSpace(query[:loc[0]]), strings.TrimSpace(query[loc[1]:])
+	}
+	return strings.TrimSpace(query), ""
+}
+
+func insideQuotes(prefix string) bool {
+	var quote rune
+	escaped := false
+	for _, r := range prefix {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		}
+	}
+	return quote != 0
+}
@@ -0,0 +1,100 @@
+package jql
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `""`},
+		{"In Progress", `"In Progress"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\temp`, `"C:\\temp"`},
+		{`\"`, `"\\\""`},
+		{"it's", `"it's"`},
+	}
+	for _, test := range tests {
+		if got := Quote(test.value); got != test.want {
+			t.Errorf("Quote(%q) = %s, want %s", test.value, got, test.want)
+		}
+		if got := String(test.value).String(); got != test.want {
+			t.Errorf("String(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "currentUser", want: "currentUser()"},
+		{name: "startOfDay", args: []string{"-7d"}, want: `startOfDay("-7d")`},
+		{name: "membersOf", args: []string{`a "b"`, "c"}, want: `membersOf("a \"b\"", "c")`},
+		{name: "", wantErr: true},
+		{name: "now() OR 1", wantErr: true},
+		{name: "7days", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := Func(test.name, test.args...)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Func(%q) = %s, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != test.want {
+			t.Errorf("Func(%q, %q) = %s, %v, want %s", test.name, test.args, got, err, test.want)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *Query
+		want  string
+	}{
+		{"empty", New(), ""},
+		{"eq", New().Eq("project", "ABC"), `project = "ABC"`},
+		{"in", New().In("status", "To Do", `Say "Done"`), `status in ("To Do", "Say \"Done\"")`},
+		{"empty in is ignored", New().In("status").Eq("project", "ABC"), `project = "ABC"`},
+		{"not in", New().NotIn("type", "Epic"), `type not in ("Epic")`},
+		{"quoted field", New().Eq("Story Points", "3"), `"Story Points" = "3"`},
+		{"custom field", New().Where("cf[10010]", "=", Number(42)), `cf[10010] = 42`},
+		{"is empty", New().IsEmpty("resolution"), `resolution is EMPTY`},
+		{"func", New().Where("assignee", "=", MustFunc("currentUser")), `assignee = currentUser()`},
+		{
+			"any",
+			New().Eq("project", "ABC").Any(New().Eq("status", "Open"), New().Eq("a", "1").Eq("b", "2")),
+			`project = "ABC" AND (status = "Open" OR (a = "1" AND b = "2"))`,
+		},
+		{
+			"raw order by is moved to the end",
+			New().Raw(`text ~ "order by" ORDER BY created DESC`).Eq("project", "ABC"),
+			`(text ~ "order by") AND project = "ABC" ORDER BY created DESC`,
+		},
+		{"sort by", New().Eq("project", "ABC").SortBy("priority, -updated"), `project = "ABC" ORDER BY priority ASC, updated DESC`},
+	}
+	for _, test := range tests {
+		if got := test.query.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := Split(" a, ,b ,, c ")
+	want := []string{"a", "b", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("Split = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Split = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package jql
+
+import (
+	"fmt"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+type parseRequest struct {
+	Queries []string `json:"queries"`
+}
+
+type parseResult struct {
+	Queries []struct {
+		Query  string   `json:"query"`
+		Errors []string `json:"errors"`
+	} `json:"queries"`
+}
+
+// Validate asks the Jira server to parse the query without running it and
+// returns the parse errors, if any
+func Validate(jiraClient *jira.Client, query string) error {
+	req, err := jiraClient.NewRequest("POST", "rest/api/2/jql/parse?validation=strict", &parseRequest{Queries: []string{query}})
+	if err != nil {
+		return err
+	}
+
+	var result parseResult
+	if _, err := jiraClient.Do(req, &result); err != nil {
+		return fmt.Errorf("could not validate query %q: %s", query, err)
+	}
+
+	for _, parsed := range result.Queries {
+		if len(parsed.Errors) > 0 {
+			return fmt.Errorf("invalid query %q: %s", query, strings.Join(parsed.Errors, "; "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package markup
+
+import "strings"
+
+// node is an Atlassian Document Format node
+type node map[string]interface{}
+
+// ToADF converts markdown into an Atlassian Document Format document, the format
+// Jira Cloud's v3 API uses for rich text
+func ToADF(markdown string, mentions MentionFunc) map[string]interface{} {
+	content := []node{}
+	for _, b := range parseBlocks(markdown) {
+		switch b.kind {
+		case heading:
+			content = append(content, node{"type": "heading", "attrs": node{"level": b.level}, "content": adfInline(b.lines[0], mentions)})
+		case paragraph:
+			content = append(content, adfParagraph(b.lines, mentions))
+		case bulletList, orderedList:
+			listType := "bulletList"
+			if b.kind == orderedList {
+				listType = "orderedList"
+			}
+			var items []node
+			for _, line := range b.lines {
+				items = append(items, node{"type": "listItem", "content": []node{adfParagraph([]string{line}, mentions)}})
+			}
+			content = append(content, node{"type": listType, "content": items})
+		case codeBlock:
+			codeNode := node{"type": "codeBlock", "attrs": node{"language": b.lang}}
+			if text := strings.Join(b.lines, "\n"); text != "" {
+				codeNode["content"] = []node{{"type": "text", "text": text}}
+			}
+			content = append(content, codeNode)
+		case quote:
+			content = append(content, node{"type": "blockquote", "content": []node{adfParagraph(b.lines, mentions)}})
+		case rule:
+			content = append(content, node{"type": "rule"})
+		}
+	}
+	return map[string]interface{}{"type": "doc", "version": 1, "content": content}
+}
+
+func adfParagraph(lines []string, mentions MentionFunc) node {
+	paragraphNode := node{"type": "paragraph"}
+	if inlines := adfInline(strings.Join(lines, " "), mentions); len(inlines) > 0 {
+		paragraphNode["content"] = inlines
+	}
+	return paragraphNode
+}
+
+func adfInline(line string, mentions MentionFunc) []node {
+	nodes := []node{}
+	for _, i := range parseInline(line, mentions) {
+		if i.text == "" {
+			continue
+		}
+		textNode := node{"type": "text", "text": i.text}
+		switch i.kind {
+		case strong:
+			textNode["marks"] = []node{{"type": "strong"}}
+		case em:
+			textNode["marks"] = []node{{"type": "em"}}
+		case code:
+			textNode["marks"] = []node{{"type": "code"}}
+		case strike:
+			textNode["marks"] = []node{{"type": "strike"}}
+		case link:
+			textNode["marks"] = []node{{"type": "link", "attrs": node{"href": i.url}}}
+		case mention:
+			textNode = node{"type": "mention", "attrs": node{"id": i.id, "text": "@" + i.text}}
+		}
+		nodes = append(nodes, textNode)
+	}
+	return nodes
+}
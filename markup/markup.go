@@ -0,0 +1,188 @@
+package markup
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MentionFunc resolves the name after an @ to a user id and display name. It
+// returns false when no user matches, and the @name is kept as text.
+type MentionFunc func(name string) (id string, display string, ok bool)
+
+type blockKind int
+
+const (
+	paragraph blockKind = iota
+	heading
+	bulletList
+	orderedList
+	codeBlock
+	quote
+	rule
+)
+
+// block is a top level markdown element. Lists have one line per item; code
+// blocks keep their lines as written.
+type block struct {
+	kind  blockKind
+	level int
+	lang  string
+	lines []string
+}
+
+type inlineKind int
+
+const (
+	text inlineKind = iota
+	strong
+	em
+	code
+	strike
+	link
+	mention
+)
+
+type inline struct {
+	kind inlineKind
+	text string
+	url  string
+	id   string
+}
+
+var (
+	headingLine = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletLine  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedLine = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	ruleLine    = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	quoteLine   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	fenceLine   = regexp.MustCompile("^\\s*```\\s*([\\w+#.-]*)\\s*$")
+
+	inlinePattern = regexp.MustCompile("`([^`]+)`" +
+		`|\*\*(.+?)\*\*` +
+		`|__(.+?)__` +
+		`|~~(.+?)~~` +
+		`|\[([^\]]+)\]\(([^)\s]+)\)` +
+		`|\*([^*\s](?:[^*]*[^*\s])?)\*` +
+		`|\b_([^_\s](?:[^_]*[^_\s])?)_\b` +
+		`|(^|\s)@([A-Za-z0-9._+-]+(?:@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)?)`)
+)
+
+func startsBlock(line string) bool {
+	return headingLine.MatchString(line) || bulletLine.MatchString(line) || orderedLine.MatchString(line) ||
+		ruleLine.MatchString(line) || quoteLine.MatchString(line) || fenceLine.MatchString(line)
+}
+
+// parseBlocks splits markdown into headings, paragraphs, lists, code blocks,
+// quotes and horizontal rules
+func parseBlocks(markdown string) []block {
+	lines := strings.Split(strings.Replace(markdown, "\r\n", "\n", -1), "\n")
+	var blocks []block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fenceLine.MatchString(line):
+			b := block{kind: codeBlock, lang: fenceLine.FindStringSubmatch(line)[1]}
+			for i++; i < len(lines) && !fenceLine.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, lines[i])
+			}
+			i++
+			blocks = append(blocks, b)
+		case headingLine.MatchString(line):
+			match := headingLine.FindStringSubmatch(line)
+			blocks = append(blocks, block{kind: heading, level: len(match[1]), lines: []string{match[2]}})
+			i++
+		case ruleLine.MatchString(line):
+			blocks = append(blocks, block{kind: rule})
+			i++
+		case quoteLine.MatchString(line):
+			b := block{kind: quote}
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			blocks = append(blocks, b)
+		case bulletLine.MatchString(line):
+			b := block{kind: bulletList}
+			for ; i < len(lines) && bulletLine.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, bulletLine.FindStringSubmatch(lines[i])[1])
+			}
+			blocks = append(blocks, b)
+		case orderedLine.MatchString(line):
+			b := block{kind: orderedList}
+			for ; i < len(lines) && orderedLine.MatchString(lines[i]); i++ {
+				b.lines = append(b.lines, orderedLine.FindStringSubmatch(lines[i])[1])
+			}
+			blocks = append(blocks, b)
+		default:
+			b := block{kind: paragraph}
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(b.lines) == 0 || !startsBlock(lines[i])); i++ {
+				b.lines = append(b.lines, strings.TrimSpace(lines[i]))
+			}
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// parseInline splits a line of markdown into text with marks, links and mentions
+func parseInline(line string, mentions MentionFunc) []inline {
+	var inlines []inline
+	addText := func(s string) {
+		if s == "" {
+			return
+		}
+		if n := len(inlines); n > 0 && inlines[n-1].kind == text {
+			inlines[n-1].text += s
+			return
+		}
+		inlines = append(inlines, inline{kind: text, text: s})
+	}
+
+	last := 0
+	for _, m := range inlinePattern.FindAllStringSubmatchIndex(line, -1) {
+		addText(line[last:m[0]])
+		last = m[1]
+		group := func(n int) string {
+			if m[2*n] < 0 {
+				return ""
+			}
+			return line[m[2*n]:m[2*n+1]]
+		}
+		switch {
+		case m[2] >= 0:
+			inlines = append(inlines, inline{kind: code, text: group(1)})
+		case m[4] >= 0:
+			inlines = append(inlines, inline{kind: strong, text: group(2)})
+		case m[6] >= 0:
+			inlines = append(inlines, inline{kind: strong, text: group(3)})
+		case m[8] >= 0:
+			inlines = append(inlines, inline{kind: strike, text: group(4)})
+		case m[10] >= 0:
+			inlines = append(inlines, inline{kind: link, text: group(5), url: group(6)})
+		case m[14] >= 0:
+			inlines = append(inlines, inline{kind: em, text: group(7)})
+		case m[16] >= 0:
+			inlines = append(inlines, inline{kind: em, text: group(8)})
+		default:
+			addText(group(9))
+			name := strings.TrimRight(group(10), ".")
+			trailing := group(10)[len(name):]
+			if id, display, ok := lookupMention(mentions, name); ok {
+				inlines = append(inlines, inline{kind: mention, text: display, id: id})
+			} else {
+				addText("@" + name)
+			}
+			addText(trailing)
+		}
+	}
+	addText(line[last:])
+	return inlines
+}
+
+func lookupMention(mentions MentionFunc, name string) (string, string, bool) {
+	if mentions == nil {
+		return "", "", false
+	}
+	return mentions(name)
+}
@@ -0,0 +1,100 @@
+package markup
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testMentions(name string) (string, string, bool) {
+	if name == "jane" {
+		return "jane.doe", "Jane Doe", true
+	}
+	return "", "", false
+}
+
+func TestToWiki(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"# Title", "h1. Title"},
+		{"Some **bold**, *em* and ~~gone~~ text", `Some *bold*, _em_ and -gone- text`},
+		{"Run `a_b {x}`", "Run {{a_b {x}}}"},
+		{"See [the docs](https://example.com/a_b)", "See [the docs|https://example.com/a_b]"},
+		{"- one\n- two", "* one\n* two"},
+		{"1. one\n2. two", "# one\n# two"},
+		{"```go\nfmt.Println()\n```", "{code:go}\nfmt.Println()\n{code}"},
+		{"> quoted", "{quote}\nquoted\n{quote}"},
+		{"---", "----"},
+		{"first\n\nsecond", "first\n\nsecond"},
+		{"ask @jane or @bob", "ask [~jane.doe] or @bob"},
+		{"a-b {x} [y] | z", `a-b \{x\} \[y\] \| z`},
+		{"2 + 2 ^ 3 ~ ? !", "2 + 2 ^ 3 ~ ? !"},
+		{"-gone +new ^up ~down ??cite?? !img.png!", `\-gone \+new \^up \~down \??cite?? \!img.png!`},
+		{"ABC-123 is well-known, unlike C++ and snake_case", "ABC-123 is well-known, unlike C++ and snake_case"},
+		{"#1 first", `\#1 first`},
+		{`C:\temp`, `C:\\temp`},
+		{"visit https://example.com/a-b_c", "visit https://example.com/a-b_c"},
+	}
+	for _, test := range tests {
+		if got := ToWiki(test.markdown, testMentions); got != test.want {
+			t.Errorf("ToWiki(%q) = %q, want %q", test.markdown, got, test.want)
+		}
+	}
+}
+
+func TestToADF(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"", `{"content":[],"type":"doc","version":1}`},
+		{"## Title", `{"content":[{"attrs":{"level":2},"content":[{"text":"Title","type":"text"}],"type":"heading"}],"type":"doc","version":1}`},
+		{"**bold** `code`", `{"content":[{"content":[{"marks":[{"type":"strong"}],"text":"bold","type":"text"},{"text":" ","type":"text"},{"marks":[{"type":"code"}],"text":"code","type":"text"}],"type":"paragraph"}],"type":"doc","version":1}`},
+		{"[docs](https://example.com)", `{"content":[{"content":[{"marks":[{"attrs":{"href":"https://example.com"},"type":"link"}],"text":"docs","type":"text"}],"type":"paragraph"}],"type":"doc","version":1}`},
+		{"- a_b", `{"content":[{"content":[{"content":[{"content":[{"text":"a_b","type":"text"}],"type":"paragraph"}],"type":"listItem"}],"type":"bulletList"}],"type":"doc","version":1}`},
+		{"hi @jane", `{"content":[{"content":[{"text":"hi ","type":"text"},{"attrs":{"id":"jane.doe","text":"@Jane Doe"},"type":"mention"}],"type":"paragraph"}],"type":"doc","version":1}`},
+		{"```\n```", `{"content":[{"attrs":{"language":""},"type":"codeBlock"}],"type":"doc","version":1}`},
+		{"***", `{"content":[{"type":"rule"}],"type":"doc","version":1}`},
+	}
+	for _, test := range tests {
+		encoded, err := json.Marshal(ToADF(test.markdown, testMentions))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(encoded); got != test.want {
+			t.Errorf("ToADF(%q) = %s, want %s", test.markdown, got, test.want)
+		}
+	}
+}
+
+func TestWikiToText(t *testing.T) {
+	tests := []struct {
+		wiki string
+		want string
+	}{
+		{"h2. Title", "Title"},
+		{"*bold* and _em_", "bold and em"},
+		{"{{code}} [docs|https://example.com] [https://example.com]", "code docs (https://example.com) https://example.com"},
+		{"[~jane.doe] and [~accountid:123]", "@jane.doe and @123"},
+		{"* one\n** two\n# three", "• one\n  • two\n- three"},
+		{"Run:\n{code:go}\nfmt.Println()\n{code}", "Run:\n    fmt.Println()"},
+		{"{quote}quoted{quote}", "> quoted"},
+		{"{color:red}red{color}", "red"},
+		{"first\r\n\r\n\r\nsecond", "first\n\nsecond"},
+		{`a\-b \*not bold\* \[x\|y\]`, "a-b *not bold* [x|y]"},
+	}
+	for _, test := range tests {
+		if got := WikiToText(test.wiki); got != test.want {
+			t.Errorf("WikiToText(%q) = %q, want %q", test.wiki, got, test.want)
+		}
+	}
+}
+
+func TestWikiRoundTrip(t *testing.T) {
+	for _, text := range []string{"a-b {x} [y|z] 2+2 #1 !important", `C:\temp`, "see https://example.com/a_b"} {
+		if got := WikiToText(ToWiki(text, nil)); got != text {
+			t.Errorf("WikiToText(ToWiki(%q)) = %q", text, got)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	wikiHeading   = regexp.MustCompile(`^h[1-6]\.\s+(.*)$`)
+	wikiList      = regexp.MustCompile(`^([*#-]+)\s+(.*)$`)
+	wikiBlock     = regexp.MustCompile(`^\{(code|noformat|quote|panel)(:[^}]*)?\}(.*)$`)
+	wikiBlockEnd  = regexp.MustCompile(`^(.*)\{(code|noformat|quote|panel)\}$`)
+	wikiLink      = regexp.MustCompile(`\[([^|\]]+)\|([^\]]+)\]`)
+	wikiBareLink  = regexp.MustCompile(`\[((?:https?|mailto):[^\]]+)\]`)
+	wikiMention   = regexp.MustCompile(`\[~(?:accountid:)?([^\]]+)\]`)
+	wikiMonospace = regexp.MustCompile(`\{\{(.+?)\}\}`)
+	wikiStrong    = regexp.MustCompile(`(^|\W)\*(\S(?:[^*]*\S)?)\*(\W|$)`)
+	wikiEm        = regexp.MustCompile(`(^|\W)_(\S(?:[^_]*\S)?)_(\W|$)`)
+	wikiColor     = regexp.MustCompile(`\{color(:[^}]*)?\}`)
+	wikiEscaped   = regexp.MustCompile(`\\([!-/:-@\[-` + "`" + `{-~])`)
+	blankLines    = regexp.MustCompile(`\n[ >]*\n(?:[ >]*\n)+`)
+)
+
+// ADFToText renders an Atlassian Document Format document as plain text for the terminal
+func ADFToText(doc interface{}) string {
+	var sb strings.Builder
+	adfText(&sb, doc, "")
+	return strings.TrimSpace(blankLines.ReplaceAllString(sb.String(), "\n\n"))
+}
+
+func adfText(sb *strings.Builder, value interface{}, indent string) {
+	n, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	attrs, _ := n["attrs"].(map[string]interface{})
+	content, _ := n["content"].([]interface{})
+	children := func(indent string) {
+		for _, child := range content {
+			adfText(sb, child, indent)
+		}
+	}
+
+	switch n["type"] {
+	case "text":
+		text, _ := n["text"].(string)
+		sb.WriteString(text)
+		marks, _ := n["marks"].([]interface{})
+		for _, mark := range marks {
+			m, _ := mark.(map[string]interface{})
+			markAttrs, _ := m["attrs"].(map[string]interface{})
+			if href, _ := markAttrs["href"].(string); m["type"] == "link" && href != "" && href != text {
+				sb.WriteString(" (" + href + ")")
+			}
+		}
+	case "hardBreak":
+		sb.WriteString("\n" + indent)
+	case "mention", "emoji", "status", "date":
+		for _, key := range []string{"text", "shortName", "timestamp"} {
+			if text, ok := attrs[key].(string); ok {
+				sb.WriteString(text)
+				break
+			}
+		}
+	case "inlineCard", "blockCard", "embedCard":
+		url, _ := attrs["url"].(string)
+		sb.WriteString(url)
+	case "paragraph", "heading":
+		sb.WriteString(indent)
+		children(indent)
+		sb.WriteString("\n\n")
+	case "bulletList", "orderedList":
+		for i, item := range content {
+			marker := "• "
+			if n["type"] == "orderedList" {
+				marker = fmt.Sprintf("%d. ", i+1)
+			}
+			var itemText strings.Builder
+			adfText(&itemText, item, indent+strings.Repeat(" ", len([]rune(marker))))
+			text := strings.TrimLeft(strings.TrimRight(itemText.String(), "\n"), " ")
+			sb.WriteString(indent + marker + text + "\n")
+		}
+		sb.WriteString("\n")
+	case "listItem":
+		for i, child := range content {
+			var childText strings.Builder
+			adfText(&childText, child, indent)
+			text := strings.TrimRight(childText.String(), "\n")
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(text)
+		}
+	case "codeBlock":
+		var code strings.Builder
+		for _, child := range content {
+			adfText(&code, child, "")
+		}
+		for _, line := range strings.Split(strings.TrimRight(code.String(), "\n"), "\n") {
+			sb.WriteString(indent + "    " + line + "\n")
+		}
+		sb.WriteString("\n")
+	case "blockquote", "panel":
+		children(indent + "> ")
+	case "rule":
+		sb.WriteString(indent + "----\n\n")
+	case "table":
+		for _, row := range content {
+			r, _ := row.(map[string]interface{})
+			cells, _ := r["content"].([]interface{})
+			var texts []string
+			for _, cell := range cells {
+				var cellText strings.Builder
+				adfText(&cellText, cell, "")
+				texts = append(texts, strings.Join(strings.Fields(cellText.String()), " "))
+			}
+			sb.WriteString(indent + strings.Join(texts, " | ") + "\n")
+		}
+		sb.WriteString("\n")
+	case "mediaSingle", "mediaGroup":
+		sb.WriteString(indent + "[attachment]\n\n")
+	default:
+		children(indent)
+	}
+}
+
+// WikiToText renders Jira wiki markup as plain text for the terminal
+func WikiToText(wiki string) string {
+	var lines []string
+	block := ""
+	for _, line := range strings.Split(strings.Replace(wiki, "\r\n", "\n", -1), "\n") {
+		if match := wikiBlock.FindStringSubmatch(strings.TrimSpace(line)); match != nil && block == "" {
+			block = match[1]
+			if rest := match[3]; rest != "" {
+				line = rest
+			} else {
+				continue
+			}
+		}
+		closed := false
+		if match := wikiBlockEnd.FindStringSubmatch(line); match != nil && match[2] == block {
+			line, closed = match[1], true
+		}
+
+		switch block {
+		case "code", "noformat":
+			if !closed || line != "" {
+				lines = append(lines, "    "+line)
+			}
+		case "quote", "panel":
+			if !closed || line != "" {
+				lines = append(lines, "> "+wikiInlineText(line))
+			}
+		default:
+			lines = append(lines, wikiLineText(line))
+		}
+		if closed {
+			block = ""
+		}
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func wikiLineText(line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "----":
+		return "----"
+	case wikiHeading.MatchString(trimmed):
+		return wikiInlineText(wikiHeading.FindStringSubmatch(trimmed)[1])
+	case wikiList.MatchString(trimmed):
+		match := wikiList.FindStringSubmatch(trimmed)
+		marker := "• "
+		if strings.HasSuffix(match[1], "#") {
+			marker = "- "
+		}
+		return strings.Repeat("  ", len(match[1])-1) + marker + wikiInlineText(match[2])
+	}
+	return wikiInlineText(line)
+}
+
+func wikiInlineText(text string) string {
+	// hide escaped characters from the patterns below and restore them at the end
+	text = wikiEscaped.ReplaceAllStringFunc(text, func(escaped string) string {
+		return string(escapedBase + rune(escaped[1]))
+	})
+	text = wikiMention.ReplaceAllString(text, "@$1")
+	text = wikiLink.ReplaceAllStringFunc(text, func(link string) string {
+		match := wikiLink.FindStringSubmatch(link)
+		if match[1] == match[2] {
+			return match[2]
+		}
+		return match[1] + " (" + match[2] + ")"
+	})
+	text = wikiBareLink.ReplaceAllString(text, "$1")
+	text = wikiMonospace.ReplaceAllString(text, "$1")
+	text = wikiStrong.ReplaceAllString(text, "$1$2$3")
+	text = wikiEm.ReplaceAllString(text, "$1$2$3")
+	text = wikiColor.ReplaceAllString(text, "")
+	return strings.Map(func(r rune) rune {
+		if r >= escapedBase && r < escapedBase+128 {
+			return r - escapedBase
+		}
+		return r
+	}, text)
+}
+
+// escapedBase is the start of the private use area escaped characters are moved to
+const escapedBase rune = 0xE000
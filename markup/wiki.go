@@ -0,0 +1,124 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ToWiki converts markdown into the wiki markup Jira Server's v2 API uses for rich text.
+// Mentions become [~id], so the MentionFunc should return usernames.
+func ToWiki(markdown string, mentions MentionFunc) string {
+	var parts []string
+	for _, b := range parseBlocks(markdown) {
+		switch b.kind {
+		case heading:
+			parts = append(parts, fmt.Sprintf("h%d. %s", b.level, wikiInline(b.lines[0], mentions)))
+		case paragraph:
+			parts = append(parts, wikiInline(strings.Join(b.lines, " "), mentions))
+		case bulletList, orderedList:
+			marker := "*"
+			if b.kind == orderedList {
+				marker = "#"
+			}
+			var items []string
+			for _, line := range b.lines {
+				items = append(items, marker+" "+wikiInline(line, mentions))
+			}
+			parts = append(parts, strings.Join(items, "\n"))
+		case codeBlock:
+			open := "{code}"
+			if b.lang != "" {
+				open = "{code:" + b.lang + "}"
+			}
+			parts = append(parts, open+"\n"+strings.Join(b.lines, "\n")+"\n{code}")
+		case quote:
+			parts = append(parts, "{quote}\n"+wikiInline(strings.Join(b.lines, " "), mentions)+"\n{quote}")
+		case rule:
+			parts = append(parts, "----")
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func wikiInline(line string, mentions MentionFunc) string {
+	var sb strings.Builder
+	for _, i := range parseInline(line, mentions) {
+		lineStart := sb.Len() == 0
+		switch i.kind {
+		case strong:
+			sb.WriteString("*" + wikiEscape(i.text, false) + "*")
+		case em:
+			sb.WriteString("_" + wikiEscape(i.text, false) + "_")
+		case code:
+			sb.WriteString("{{" + i.text + "}}")
+		case strike:
+			sb.WriteString("-" + wikiEscape(i.text, false) + "-")
+		case link:
+			sb.WriteString("[" + wikiEscape(i.text, false) + "|" + i.url + "]")
+		case mention:
+			sb.WriteString("[~" + i.id + "]")
+		default:
+			sb.WriteString(wikiEscape(i.text, lineStart))
+		}
+	}
+	return sb.String()
+}
+
+// wikiSpecial are the characters wiki markup reads as formatting wherever they appear
+const wikiSpecial = `\{}[]|`
+
+// wikiPhraseSpecial start phrase formatting such as *bold*, -deleted- or !image!
+// when they open a word, so they are left alone inside words like ABC-123 or C++
+const wikiPhraseSpecial = `*_-+^~!`
+
+// wikiLineSpecial start lists and rules at the beginning of a line
+const wikiLineSpecial = `*#-`
+
+var bareURL = regexp.MustCompile(`(?:https?|mailto):[^\s]+`)
+
+// wikiEscape puts a backslash before the characters wiki markup would read as
+// formatting at their position, leaving URLs alone so Jira still links them.
+// lineStart tells whether the text begins a line.
+func wikiEscape(text string, lineStart bool) string {
+	urls := bareURL.FindAllStringIndex(text, -1)
+	runes := []rune(text)
+	var sb strings.Builder
+	offset := 0
+	for i, r := range runes {
+		inURL := false
+		for _, u := range urls {
+			if offset >= u[0] && offset < u[1] {
+				inURL = true
+			}
+		}
+		offset += utf8.RuneLen(r)
+		if inURL {
+			sb.WriteRune(r)
+			continue
+		}
+
+		var prev, next rune
+		if i > 0 {
+			prev = runes[i-1]
+		}
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case strings.ContainsRune(wikiSpecial, r),
+			i == 0 && lineStart && strings.ContainsRune(wikiLineSpecial, r),
+			r == '?' && next == '?' && !isWordRune(prev),
+			strings.ContainsRune(wikiPhraseSpecial, r) && !isWordRune(prev) && next != 0 && !unicode.IsSpace(next):
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}